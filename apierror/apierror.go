@@ -0,0 +1,182 @@
+// Package apierror is the error model of the API. Handlers return errors
+// carrying a machine-readable Code, and Middleware renders them as RFC 7807
+// problem details with the status code of that Code.
+package apierror
+
+import (
+	"blogo/logging"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code identifies the kind of an error to clients.
+type Code string
+
+const (
+	CodeBadRequest      Code = "bad_request"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeForbidden       Code = "forbidden"
+	CodeNotFound        Code = "not_found"
+	CodeConflict        Code = "conflict"
+	CodeTooLarge        Code = "too_large"
+	CodeInternal        Code = "internal"
+)
+
+// Status returns the HTTP status code of errors with code c.
+func (c Code) Status() int {
+	switch c {
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error the API reports to the client.
+type Error struct {
+	Code Code
+	// Detail explains the error to the client.
+	Detail string
+	// Fields lists what is wrong with the fields of the request body.
+	Fields []FieldError
+	// Err is the underlying error. It is logged but never shown to
+	// clients.
+	Err error
+}
+
+// FieldError is a rule a field of the request body breaks.
+type FieldError struct {
+	// Field is the JSON name of the field, such as postTags[2].
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New creates an error with a detail formatted from format and args.
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Invalid creates a bad request error for a body whose fields break the
+// rules.
+func Invalid(fields []FieldError) *Error {
+	return &Error{Code: CodeBadRequest, Detail: "the request body is invalid", Fields: fields}
+}
+
+// Wrap creates an error caused by err. Unless the code is CodeInternal, the
+// message of err is shown to the client as the detail.
+func Wrap(code Code, err error) *Error {
+	e := &Error{Code: code, Err: err}
+	if code != CodeInternal {
+		e.Detail = err.Error()
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Detail != e.Err.Error() {
+		if e.Detail == "" {
+			return fmt.Sprintf("%s: %v", e.Code, e.Err)
+		}
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+	// Errors lists field errors, for invalid request bodies.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Handler adapts a handler that returns its errors. A returned error aborts
+// the chain and is rendered by Middleware.
+func Handler(h func(c *gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h(c); err != nil {
+			Abort(c, err)
+		}
+	}
+}
+
+// Abort stops the chain with err, for middleware that rejects a request.
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// Middleware renders the last error of a request as problem details unless
+// a response has been written already. classify turns errors that are not
+// an *Error into one; it may return nil for errors it does not know, which
+// are reported as internal errors.
+func Middleware(classify func(err error) *Error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) {
+			if apiErr = classify(err); apiErr == nil {
+				apiErr = Wrap(CodeInternal, err)
+			}
+		}
+
+		logger := logging.FromContext(c.Request.Context())
+		if apiErr.Code.Status() >= http.StatusInternalServerError {
+			logger.Error("Request failed", "code", apiErr.Code, "error", err)
+		} else {
+			logger.Debug("Request rejected", "code", apiErr.Code, "error", err)
+		}
+		Render(c, apiErr)
+	}
+}
+
+// Render writes err as problem details.
+func Render(c *gin.Context, err *Error) {
+	status := err.Code.Status()
+	detail := err.Detail
+	if detail == "" && err.Code == CodeInternal {
+		detail = "the server failed to handle the request"
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      err.Code,
+		RequestID: c.Writer.Header().Get(logging.RequestIDHeader),
+		Errors:    err.Fields,
+	})
+}
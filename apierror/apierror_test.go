@@ -0,0 +1,105 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errKnown = errors.New("no such thing")
+
+func classify(err error) *Error {
+	if errors.Is(err, errKnown) {
+		return Wrap(CodeNotFound, err)
+	}
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(classify))
+	router.GET("/api", Handler(func(c *gin.Context) error {
+		return New(CodeConflict, "title %q is taken", "Hello")
+	}))
+	router.GET("/known", Handler(func(c *gin.Context) error {
+		return fmt.Errorf("load post: %w", errKnown)
+	}))
+	router.GET("/unknown", Handler(func(c *gin.Context) error {
+		return errors.New("connection reset by peer")
+	}))
+	router.GET("/written", Handler(func(c *gin.Context) error {
+		c.String(http.StatusAccepted, "partial")
+		return errors.New("too late")
+	}))
+
+	tests := []struct {
+		path   string
+		status int
+		code   Code
+		detail string
+	}{
+		{"/api", http.StatusConflict, CodeConflict, `title "Hello" is taken`},
+		{"/known", http.StatusNotFound, CodeNotFound, "load post: no such thing"},
+		// the cause of internal errors is never shown
+		{"/unknown", http.StatusInternalServerError, CodeInternal, "the server failed to handle the request"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != ContentType {
+			t.Errorf("%s: got content type %q", tt.path, ct)
+		}
+		var problem Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+		want := Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(tt.status),
+			Status:   tt.status,
+			Detail:   tt.detail,
+			Instance: tt.path,
+			Code:     tt.code,
+		}
+		if !reflect.DeepEqual(problem, want) {
+			t.Errorf("%s: got %+v, want %+v", tt.path, problem, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/written", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Errorf("/written: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	cause := errors.New("duplicate key")
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{New(CodeBadRequest, "limit must be positive"), "bad_request: limit must be positive"},
+		{Wrap(CodeConflict, cause), "conflict: duplicate key"},
+		{Wrap(CodeInternal, cause), "internal: duplicate key"},
+		{&Error{Code: CodeConflict, Detail: "username taken", Err: cause}, "conflict: username taken: duplicate key"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+	if !errors.Is(Wrap(CodeInternal, cause), cause) {
+		t.Error("a wrapped error does not unwrap to its cause")
+	}
+}
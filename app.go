@@ -0,0 +1,285 @@
+package main
+
+import (
+	"blogo/config"
+	"blogo/counters"
+	"blogo/handlers"
+	"blogo/jobs"
+	"blogo/logging"
+	"blogo/metrics"
+	"blogo/search"
+	"blogo/store"
+	"blogo/tokens"
+	"blogo/validation"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/boj/redistore"
+	sessionRedisStore "github.com/gin-contrib/sessions/redis"
+	"github.com/go-redis/redis"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// App owns the connections, handlers and HTTP server of the API.
+type App struct {
+	cfg    config.Config
+	logger *slog.Logger
+
+	mongo        *mongo.Client
+	redis        *redis.Client
+	sessions     sessionRedisStore.Store
+	sessionRedis *redistore.RediStore
+
+	posts    *handlers.PostsHandler
+	comments *handlers.CommentsHandler
+	auth     *handlers.AuthHandler
+	admin    *handlers.AdminHandler
+	trash    *handlers.TrashHandler
+	feeds    *handlers.FeedHandler
+	health   *handlers.HealthHandler
+	authMode handlers.AuthMode
+
+	// registry holds the metrics read from the databases of this App.
+	registry *prometheus.Registry
+	server   *http.Server
+}
+
+// NewApp connects to MongoDB and Redis and builds the handlers. ctx bounds
+// the connection attempts only.
+func NewApp(ctx context.Context, cfg config.Config, logger *slog.Logger) (*App, error) {
+	if err := validation.Setup(); err != nil {
+		return nil, err
+	}
+
+	app := &App{cfg: cfg, logger: logger, registry: prometheus.NewRegistry()}
+	if err := app.connect(ctx); err != nil {
+		app.close(context.Background())
+		return nil, err
+	}
+	if err := app.buildHandlers(ctx); err != nil {
+		app.close(context.Background())
+		return nil, err
+	}
+
+	app.server = &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: app.routes(),
+	}
+	return app, nil
+}
+
+func (app *App) connect(ctx context.Context) error {
+	var err error
+	app.mongo, err = mongo.Connect(ctx, options.Client().ApplyURI(app.cfg.Mongo.URI).SetMonitor(metrics.MongoMonitor()))
+	if err != nil {
+		return err
+	}
+	if err = app.mongo.Ping(ctx, readpref.Primary()); err != nil {
+		return fmt.Errorf("ping MongoDB: %v", err)
+	}
+	app.logger.Info("Connected to MongoDB")
+
+	app.redis = redis.NewClient(&redis.Options{
+		Addr:     app.cfg.Redis.Addr,
+		Password: app.cfg.Redis.Password,
+		DB:       app.cfg.Redis.DB,
+	})
+	if err = app.redis.Ping().Err(); err != nil {
+		return fmt.Errorf("ping Redis: %v", err)
+	}
+	app.logger.Info("Connected to Redis", "addr", app.cfg.Redis.Addr)
+
+	app.sessions, err = sessionRedisStore.NewStore(10, "tcp", app.cfg.Session.RedisAddr, app.cfg.Session.RedisPassword, []byte(app.cfg.Session.Secret))
+	if err != nil {
+		return fmt.Errorf("connect to session Redis: %v", err)
+	}
+	err, app.sessionRedis = sessionRedisStore.GetRedisStore(app.sessions)
+	return err
+}
+
+func (app *App) buildHandlers(ctx context.Context) error {
+	database := app.mongo.Database(app.cfg.Mongo.Database)
+
+	searchEngine := search.NewMongoEngine(database.Collection("posts"))
+	if err := searchEngine.EnsureIndex(ctx); err != nil {
+		return err
+	}
+	postStore := store.NewMongoPostStore(database.Collection("posts"))
+	if err := postStore.EnsureIndex(ctx); err != nil {
+		return err
+	}
+	reactionStore := store.NewMongoReactionStore(database.Collection("reactions"))
+	if err := reactionStore.EnsureIndex(ctx); err != nil {
+		return err
+	}
+	userStore := store.NewMongoUserStore(database.Collection("users"))
+
+	var err error
+	app.authMode, err = handlers.ParseAuthMode(app.cfg.Auth.Mode)
+	if err != nil {
+		return err
+	}
+	var tokenManager *tokens.Manager
+	if app.authMode.UsesTokens() {
+		tokenManager = tokens.NewManager([]byte(app.cfg.Auth.JWTSecret), app.cfg.Auth.AccessTTL, app.cfg.Auth.RefreshTTL, tokens.NewRedisDenylist(app.redis))
+	}
+
+	app.posts = handlers.NewPostsHandlers(postStore, store.NewMongoRevisionStore(database.Collection("revisions")), store.NewMongoTagStore(database.Collection("tags")), reactionStore, searchEngine, app.redis)
+	app.comments = handlers.NewCommentsHandlers(store.NewMongoCommentStore(database.Collection("comments")), postStore, reactionStore, app.redis)
+	app.auth = handlers.NewAuthHandler(userStore, app.authMode, tokenManager)
+	app.admin = handlers.NewAdminHandler(userStore)
+	app.trash = handlers.NewTrashHandler(app.posts, app.comments, app.cfg.TrashRetention)
+	app.feeds = handlers.NewFeedHandler(app.posts, app.cfg.Feed.Title, app.cfg.Feed.URL, app.cfg.Feed.Size)
+	app.health = handlers.NewHealthHandler(app.healthChecks()...)
+	app.registerMetrics(database)
+	return nil
+}
+
+// sampleTimeout bounds each value read when Prometheus scrapes.
+const sampleTimeout = 2 * time.Second
+
+// registerMetrics adds the gauges that are read from the databases on
+// every scrape.
+func (app *App) registerMetrics(database *mongo.Database) {
+	documents := make(map[string]metrics.Sampler)
+	for _, name := range []string{"posts", "comments", "users"} {
+		collection := database.Collection(name)
+		documents[name] = func(ctx context.Context) (float64, error) {
+			n, err := collection.EstimatedDocumentCount(ctx)
+			return float64(n), err
+		}
+	}
+	metrics.RegisterSampled(app.registry, "documents", "Number of documents by collection.", "collection", sampleTimeout, documents)
+
+	metrics.RegisterSampled(app.registry, "active_sessions", "Number of sessions in the session store.", "", sampleTimeout, map[string]metrics.Sampler{
+		"": app.countSessions,
+	})
+}
+
+// countSessions counts the keys of the session store. Expired sessions are
+// removed by Redis, so every key is a live session.
+func (app *App) countSessions(ctx context.Context) (float64, error) {
+	conn := app.sessionRedis.Pool.Get()
+	defer conn.Close()
+
+	count, cursor := 0, 0
+	for {
+		values, err := redigo.Values(conn.Do("SCAN", cursor, "MATCH", "session_*", "COUNT", 1000))
+		if err != nil {
+			return 0, err
+		}
+		var keys []string
+		if _, err := redigo.Scan(values, &cursor, &keys); err != nil {
+			return 0, err
+		}
+		count += len(keys)
+		if cursor == 0 {
+			return float64(count), nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// healthChecks pings MongoDB, the data Redis and the session Redis.
+func (app *App) healthChecks() []handlers.HealthCheck {
+	timeout := app.cfg.ReadinessTimeout
+	return []handlers.HealthCheck{
+		{Name: "mongo", Timeout: timeout, Ping: func(ctx context.Context) error {
+			return app.mongo.Ping(ctx, readpref.Primary())
+		}},
+		{Name: "redis", Timeout: timeout, Ping: func(ctx context.Context) error {
+			return app.redis.WithContext(ctx).Ping().Err()
+		}},
+		{Name: "sessionRedis", Timeout: timeout, Ping: func(ctx context.Context) error {
+			conn := app.sessionRedis.Pool.Get()
+			defer conn.Close()
+			_, err := conn.Do("PING")
+			return err
+		}},
+	}
+}
+
+// Run serves until ctx is done and then shuts down: it stops accepting
+// connections, waits for in-flight requests, flushes the reaction counters
+// one last time and closes the connections.
+func (app *App) Run(ctx context.Context) error {
+	flushCtx, stopFlush := context.WithCancel(context.Background())
+	flushed := make(chan struct{})
+	go func() {
+		counters.Run(flushCtx, app.cfg.CounterFlushInterval, app.posts.Counters(), app.comments.Counters())
+		close(flushed)
+	}()
+
+	jobsCtx, stopJobs := context.WithCancel(logging.NewContext(context.Background(), app.logger))
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Run(jobsCtx, app.jobs()...)
+		close(jobsDone)
+	}()
+
+	served := make(chan error, 1)
+	go func() {
+		app.logger.Info("Listening", "addr", app.server.Addr)
+		served <- app.server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		app.logger.Info("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.cfg.ShutdownTimeout)
+	defer cancel()
+	if err == nil {
+		err = app.server.Shutdown(shutdownCtx)
+	}
+	stopJobs()
+	<-jobsDone
+	stopFlush()
+	<-flushed
+	app.logger.Info("Flushed reaction counters")
+	app.close(shutdownCtx)
+	return err
+}
+
+// trashPurgeInterval is how often the trash is checked for items past their
+// retention.
+const trashPurgeInterval = time.Hour
+
+// jobs returns the background work of the server.
+func (app *App) jobs() []jobs.Job {
+	return []jobs.Job{
+		{Name: "publish-scheduled", Interval: app.cfg.SchedulerInterval, Run: app.posts.PublishScheduled},
+		{Name: "purge-trash", Interval: trashPurgeInterval, Run: app.trash.Purge},
+	}
+}
+
+// close releases whatever connections are open.
+func (app *App) close(ctx context.Context) {
+	if app.mongo != nil {
+		if err := app.mongo.Disconnect(ctx); err != nil {
+			app.logger.Error("Disconnect from MongoDB failed", "error", err)
+		}
+	}
+	if app.redis != nil {
+		if err := app.redis.Close(); err != nil {
+			app.logger.Error("Close Redis client failed", "error", err)
+		}
+	}
+	if app.sessionRedis != nil {
+		if err := app.sessionRedis.Close(); err != nil {
+			app.logger.Error("Close session Redis failed", "error", err)
+		}
+	}
+}
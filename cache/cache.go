@@ -0,0 +1,165 @@
+// Package cache is a read-through JSON cache on Redis.
+//
+// Entries belong to a group, which has a version stored at
+// cache:<name>:v:<group>. An entry lives at cache:<name>:<group>:<version>:<key>, so invalidating a group
+// only has to bump its version: the old entries are never read again and
+// expire on their own, and a load that raced with the invalidation writes
+// to the old version where nobody looks.
+package cache
+
+import (
+	"blogo/metrics"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// lockTTL bounds how long other instances wait for the one loading a
+	// cold entry.
+	lockTTL  = 5 * time.Second
+	lockPoll = 50 * time.Millisecond
+)
+
+// Loader produces the value of a missing entry.
+type Loader func() (interface{}, error)
+
+// Cache reads entries through from Redis. A Cache without a Redis client
+// calls the loader every time.
+type Cache struct {
+	client *redis.Client
+	name   string
+	prefix string
+	flight singleflight.Group
+}
+
+// New creates a cache called name, which also labels its hit and miss
+// metrics. client may be nil.
+func New(client *redis.Client, name string) *Cache {
+	return &Cache{client: client, name: name, prefix: "cache:" + name + ":"}
+}
+
+// Get decodes the entry key of group into v. On a miss it calls load and
+// keeps the result for ttl. Concurrent misses share one call of load: in
+// this process by waiting for the same call, across processes by waiting
+// for the holder of a short lock to fill the entry. Redis failures are
+// logged and fall back to load.
+func (c *Cache) Get(group, key string, ttl time.Duration, v interface{}, load Loader) error {
+	if c.client == nil {
+		c.count(false)
+		return decode(load, v)
+	}
+
+	version, err := c.client.Get(c.versionKey(group)).Int64()
+	if err != nil && err != redis.Nil {
+		slog.Error("Read cache version failed", "group", group, "error", err)
+		c.count(false)
+		return decode(load, v)
+	}
+	entry := c.entryKey(group, version, key)
+
+	data, err := c.client.Get(entry).Bytes()
+	if err == nil {
+		c.count(true)
+		return json.Unmarshal(data, v)
+	} else if err != redis.Nil {
+		slog.Error("Read cache entry failed", "entry", entry, "error", err)
+		c.count(false)
+		return decode(load, v)
+	}
+
+	c.count(false)
+
+	result, err, _ := c.flight.Do(entry, func() (interface{}, error) {
+		return c.fill(entry, ttl, load)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result.([]byte), v)
+}
+
+// fill loads the entry unless another instance already is, in which case
+// it waits for that instance to store it.
+func (c *Cache) fill(entry string, ttl time.Duration, load Loader) ([]byte, error) {
+	lock := entry + ":lock"
+	locked, err := c.client.SetNX(lock, 1, lockTTL).Result()
+	if err == nil && !locked {
+		for deadline := time.Now().Add(lockTTL); time.Now().Before(deadline); {
+			time.Sleep(lockPoll)
+			if data, err := c.client.Get(entry).Bytes(); err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	value, err := load()
+	if err != nil {
+		if locked {
+			c.client.Del(lock)
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.Set(entry, data, ttl)
+	if locked {
+		pipe.Del(lock)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		slog.Error("Write cache entry failed", "entry", entry, "error", err)
+	}
+	return data, nil
+}
+
+// Invalidate drops all entries of groups.
+func (c *Cache) Invalidate(groups ...string) {
+	if c.client == nil || len(groups) == 0 {
+		return
+	}
+	pipe := c.client.Pipeline()
+	for _, group := range groups {
+		pipe.Incr(c.versionKey(group))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		slog.Error("Invalidate cache groups failed", "groups", groups, "error", err)
+	}
+}
+
+func (c *Cache) count(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	metrics.CacheRequests.WithLabelValues(c.name, result).Inc()
+}
+
+func (c *Cache) versionKey(group string) string {
+	return c.prefix + "v:" + group
+}
+
+func (c *Cache) entryKey(group string, version int64, key string) string {
+	return c.prefix + group + ":" + strconv.FormatInt(version, 10) + ":" + key
+}
+
+// decode calls load and decodes its result into v the way a cache hit
+// would, so that callers see the same value either way.
+func decode(load Loader, v interface{}) error {
+	value, err := load()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
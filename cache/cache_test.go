@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func newTestCache(t *testing.T) (*Cache, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, "test:"), client
+}
+
+// counter is a Loader returning how often it was called.
+type counter struct {
+	calls int64
+}
+
+func (c *counter) load() (interface{}, error) {
+	return atomic.AddInt64(&c.calls, 1), nil
+}
+
+func get(t *testing.T, c *Cache, group, key string, load Loader) int64 {
+	t.Helper()
+	var v int64
+	if err := c.Get(group, key, time.Minute, &v, load); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGet(t *testing.T) {
+	c, _ := newTestCache(t)
+	var loads counter
+
+	if v := get(t, c, "posts", "a", loads.load); v != 1 {
+		t.Errorf("first Get = %d, want 1", v)
+	}
+	if v := get(t, c, "posts", "a", loads.load); v != 1 {
+		t.Errorf("second Get = %d, want the cached 1", v)
+	}
+	if v := get(t, c, "posts", "b", loads.load); v != 2 {
+		t.Errorf("Get of another key = %d, want 2", v)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c, _ := newTestCache(t)
+	var loads counter
+	get(t, c, "posts", "a", loads.load)
+	get(t, c, "post:1", "", loads.load)
+
+	c.Invalidate("posts")
+	if v := get(t, c, "posts", "a", loads.load); v != 3 {
+		t.Errorf("Get after Invalidate = %d, want a reload", v)
+	}
+	if v := get(t, c, "post:1", "", loads.load); v != 2 {
+		t.Errorf("Get of another group = %d, want the cached 2", v)
+	}
+}
+
+func TestGetError(t *testing.T) {
+	c, _ := newTestCache(t)
+	failure := errors.New("database down")
+	var v int64
+	if err := c.Get("posts", "a", time.Minute, &v, func() (interface{}, error) { return nil, failure }); err != failure {
+		t.Fatalf("Get = %v, want the error of the loader", err)
+	}
+	// errors are not cached
+	var loads counter
+	if v := get(t, c, "posts", "a", loads.load); v != 1 {
+		t.Errorf("Get after an error = %d, want 1", v)
+	}
+}
+
+func TestGetWithoutRedis(t *testing.T) {
+	c := New(nil, "test:")
+	var loads counter
+	get(t, c, "posts", "a", loads.load)
+	if v := get(t, c, "posts", "a", loads.load); v != 2 {
+		t.Errorf("Get without Redis = %d, want every call to load", v)
+	}
+	c.Invalidate("posts")
+}
+
+func TestGetStampede(t *testing.T) {
+	c, _ := newTestCache(t)
+	var loads counter
+	slow := func() (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return loads.load()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, c, "posts", "a", slow)
+		}()
+	}
+	wg.Wait()
+	if loads.calls != 1 {
+		t.Errorf("10 concurrent misses loaded %d times, want 1", loads.calls)
+	}
+}
+
+func TestGetWaitsForOtherInstance(t *testing.T) {
+	c, client := newTestCache(t)
+	entry := c.entryKey("posts", 0, "a")
+
+	// another instance holds the lock and fills the entry a little later
+	client.Set(entry+":lock", 1, lockTTL)
+	go func() {
+		time.Sleep(2 * lockPoll)
+		client.Set(entry, "42", time.Minute)
+		client.Del(entry + ":lock")
+	}()
+
+	var loads counter
+	if v := get(t, c, "posts", "a", loads.load); v != 42 || loads.calls != 0 {
+		t.Errorf("Get = %d after %d loads, want 42 from the other instance", v, loads.calls)
+	}
+}
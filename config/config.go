@@ -0,0 +1,359 @@
+// Package config loads the settings of the API server.
+//
+// Settings are read from a YAML file, then from environment variables and
+// then from command line flags, each overriding the one before. The file is
+// named by the -config flag or the CONFIG_FILE environment variable and is
+// optional.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	// Port is the TCP port the API listens on.
+	Port  int   `yaml:"port"`
+	Mongo Mongo `yaml:"mongo"`
+	// Redis holds the data cache, counters and token denylist.
+	Redis   Redis   `yaml:"redis"`
+	Session Session `yaml:"session"`
+	Auth    Auth    `yaml:"auth"`
+	CORS    CORS    `yaml:"cors"`
+	Log     Log     `yaml:"log"`
+	Feed    Feed    `yaml:"feed"`
+	// CounterFlushInterval is how often buffered reaction counts are
+	// written to MongoDB.
+	CounterFlushInterval time.Duration `yaml:"counterFlushInterval"`
+	// ShutdownTimeout bounds how long shutdown waits for in-flight
+	// requests.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// ReadinessTimeout bounds each dependency check of /readyz.
+	ReadinessTimeout time.Duration `yaml:"readinessTimeout"`
+	// MaxBodyBytes is the largest request body accepted.
+	MaxBodyBytes int `yaml:"maxBodyBytes"`
+	// SchedulerInterval is how often scheduled posts that are due get
+	// published.
+	SchedulerInterval time.Duration `yaml:"schedulerInterval"`
+	// TrashRetention is how long deleted posts and comments stay in the
+	// trash before they are purged.
+	TrashRetention time.Duration `yaml:"trashRetention"`
+}
+
+type Mongo struct {
+	URI      string `yaml:"uri"`
+	Database string `yaml:"database"`
+}
+
+type Redis struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type Session struct {
+	// RedisAddr is the Redis that stores sessions, which may differ from
+	// the data Redis.
+	RedisAddr     string `yaml:"redisAddr"`
+	RedisPassword string `yaml:"redisPassword"`
+	// Secret authenticates session cookies.
+	Secret string `yaml:"secret"`
+}
+
+type Auth struct {
+	// Mode is session, jwt or both.
+	Mode       string        `yaml:"mode"`
+	JWTSecret  string        `yaml:"jwtSecret"`
+	AccessTTL  time.Duration `yaml:"accessTTL"`
+	RefreshTTL time.Duration `yaml:"refreshTTL"`
+}
+
+type CORS struct {
+	AllowOrigins []string `yaml:"allowOrigins"`
+}
+
+type Log struct {
+	// Format is text or json.
+	Format string `yaml:"format"`
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+}
+
+// Feed describes the blog in its RSS, Atom and JSON feeds.
+type Feed struct {
+	Title string `yaml:"title"`
+	// URL is the public address of the API. Links in feeds are built from
+	// it.
+	URL string `yaml:"url"`
+	// Size is how many of the newest posts a feed holds.
+	Size int `yaml:"size"`
+}
+
+// MinSecretLength is the shortest accepted session or JWT secret.
+const MinSecretLength = 16
+
+// Default returns the settings used for whatever is not configured.
+func Default() Config {
+	return Config{
+		Port: 8080,
+		Redis: Redis{
+			Addr: "localhost:6379",
+		},
+		Session: Session{
+			RedisAddr: "localhost:6379",
+		},
+		Auth: Auth{
+			Mode:       "session",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		CORS: CORS{
+			AllowOrigins: []string{"http://localhost:3000"},
+		},
+		Log: Log{
+			Format: "text",
+			Level:  "info",
+		},
+		Feed: Feed{
+			Title: "Blogo",
+			URL:   "http://localhost:8080",
+			Size:  20,
+		},
+		CounterFlushInterval: 10 * time.Second,
+		ShutdownTimeout:      15 * time.Second,
+		ReadinessTimeout:     2 * time.Second,
+		MaxBodyBytes:         1 << 20,
+		SchedulerInterval:    30 * time.Second,
+		TrashRetention:       30 * 24 * time.Hour,
+	}
+}
+
+// setting describes one configurable value and where it comes from.
+type setting struct {
+	name   string
+	env    string
+	flag   string
+	secret bool
+	value  interface{}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{name: "port", env: "PORT", flag: "port", value: &c.Port},
+		{name: "mongo.uri", env: "MONGO_URI", flag: "mongo-uri", secret: true, value: &c.Mongo.URI},
+		{name: "mongo.database", env: "MONGO_DATABASE", flag: "mongo-database", value: &c.Mongo.Database},
+		{name: "redis.addr", env: "REDIS_ADDR", flag: "redis-addr", value: &c.Redis.Addr},
+		{name: "redis.password", env: "REDIS_PASSWORD", flag: "redis-password", secret: true, value: &c.Redis.Password},
+		{name: "redis.db", env: "REDIS_DB", flag: "redis-db", value: &c.Redis.DB},
+		{name: "session.redisAddr", env: "SESSION_REDIS_URI", flag: "session-redis-addr", value: &c.Session.RedisAddr},
+		{name: "session.redisPassword", env: "SESSION_REDIS_PASSWORD", flag: "session-redis-password", secret: true, value: &c.Session.RedisPassword},
+		{name: "session.secret", env: "SESSION_SECRET", flag: "session-secret", secret: true, value: &c.Session.Secret},
+		{name: "auth.mode", env: "AUTH_MODE", flag: "auth-mode", value: &c.Auth.Mode},
+		{name: "auth.jwtSecret", env: "JWT_SECRET", flag: "jwt-secret", secret: true, value: &c.Auth.JWTSecret},
+		{name: "auth.accessTTL", env: "JWT_ACCESS_TTL", flag: "jwt-access-ttl", value: &c.Auth.AccessTTL},
+		{name: "auth.refreshTTL", env: "JWT_REFRESH_TTL", flag: "jwt-refresh-ttl", value: &c.Auth.RefreshTTL},
+		{name: "cors.allowOrigins", env: "CORS_ORIGINS", flag: "cors-origins", value: &c.CORS.AllowOrigins},
+		{name: "log.format", env: "LOG_FORMAT", flag: "log-format", value: &c.Log.Format},
+		{name: "log.level", env: "LOG_LEVEL", flag: "log-level", value: &c.Log.Level},
+		{name: "feed.title", env: "FEED_TITLE", flag: "feed-title", value: &c.Feed.Title},
+		{name: "feed.url", env: "FEED_URL", flag: "feed-url", value: &c.Feed.URL},
+		{name: "feed.size", env: "FEED_SIZE", flag: "feed-size", value: &c.Feed.Size},
+		{name: "counterFlushInterval", env: "COUNTER_FLUSH_INTERVAL", flag: "counter-flush-interval", value: &c.CounterFlushInterval},
+		{name: "shutdownTimeout", env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", value: &c.ShutdownTimeout},
+		{name: "readinessTimeout", env: "READINESS_TIMEOUT", flag: "readiness-timeout", value: &c.ReadinessTimeout},
+		{name: "maxBodyBytes", env: "MAX_BODY_BYTES", flag: "max-body-bytes", value: &c.MaxBodyBytes},
+		{name: "schedulerInterval", env: "SCHEDULER_INTERVAL", flag: "scheduler-interval", value: &c.SchedulerInterval},
+		{name: "trashRetention", env: "TRASH_RETENTION", flag: "trash-retention", value: &c.TrashRetention},
+	}
+}
+
+// Load reads the configuration from the file, the environment as seen
+// through getenv and the command line arguments args, and validates it.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("blogo", flag.ContinueOnError)
+	file := fs.String("config", getenv("CONFIG_FILE"), "YAML configuration file")
+	flags := make(map[string]*string)
+	for _, s := range cfg.settings() {
+		flags[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.name, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return cfg, err
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %v", *file, err)
+		}
+	}
+
+	for _, s := range cfg.settings() {
+		if raw := getenv(s.env); raw != "" {
+			if err := parse(s.value, raw); err != nil {
+				return cfg, fmt.Errorf("%s: %v", s.env, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range cfg.settings() {
+			if s.flag == f.Name && err == nil {
+				if perr := parse(s.value, *flags[s.flag]); perr != nil {
+					err = fmt.Errorf("-%s: %v", s.flag, perr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// parse sets the value pointed to by ptr from its textual form. Lists are
+// comma separated.
+func parse(ptr interface{}, raw string) error {
+	switch v := ptr.(type) {
+	case *string:
+		*v = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*v = n
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		*v = d
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", ptr)
+	}
+	return nil
+}
+
+// ValidationError lists everything that is wrong with a configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
+// Validate checks that every required setting is present and every value
+// is usable.
+func (c Config) Validate() error {
+	var problems ValidationError
+	require := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	require(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535")
+	require(c.Mongo.URI != "", "mongo.uri is required")
+	require(c.Mongo.Database != "", "mongo.database is required")
+	require(c.Redis.Addr != "", "redis.addr is required")
+	require(c.Redis.DB >= 0, "redis.db must not be negative")
+	require(c.Session.RedisAddr != "", "session.redisAddr is required")
+	require(len(c.Session.Secret) >= MinSecretLength, "session.secret must be at least %d bytes", MinSecretLength)
+
+	switch c.Auth.Mode {
+	case "session":
+	case "jwt", "both":
+		require(len(c.Auth.JWTSecret) >= MinSecretLength, "auth.jwtSecret must be at least %d bytes when auth.mode is %s", MinSecretLength, c.Auth.Mode)
+	default:
+		problems = append(problems, fmt.Sprintf("auth.mode must be session, jwt or both, not %q", c.Auth.Mode))
+	}
+	require(c.Auth.AccessTTL > 0, "auth.accessTTL must be positive")
+	require(c.Auth.RefreshTTL > c.Auth.AccessTTL, "auth.refreshTTL must be longer than auth.accessTTL")
+
+	for _, origin := range c.CORS.AllowOrigins {
+		u, err := url.Parse(origin)
+		require(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"cors.allowOrigins: %q is not an origin like https://example.com", origin)
+	}
+	require(c.Log.Format == "text" || c.Log.Format == "json", "log.format must be text or json, not %q", c.Log.Format)
+	var level slog.Level
+	require(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level must be debug, info, warn or error, not %q", c.Log.Level)
+	require(c.Feed.Title != "", "feed.title is required")
+	u, err := url.Parse(c.Feed.URL)
+	require(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "",
+		"feed.url: %q is not an address like https://example.com", c.Feed.URL)
+	require(c.Feed.Size > 0 && c.Feed.Size <= 100, "feed.size must be between 1 and 100")
+	require(c.CounterFlushInterval > 0, "counterFlushInterval must be positive")
+	require(c.ShutdownTimeout > 0, "shutdownTimeout must be positive")
+	require(c.ReadinessTimeout > 0, "readinessTimeout must be positive")
+	require(c.MaxBodyBytes > 0, "maxBodyBytes must be positive")
+	require(c.SchedulerInterval > 0, "schedulerInterval must be positive")
+	require(c.TrashRetention > 0, "trashRetention must be positive")
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// LogValue renders the configuration as one attribute per setting with
+// secrets masked, for logging at startup.
+func (c Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		value := format(s.value)
+		if s.secret && value != "" {
+			value = redact(value)
+		}
+		attrs = append(attrs, slog.String(s.name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func format(ptr interface{}) string {
+	switch v := ptr.(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Duration:
+		return v.String()
+	case *[]string:
+		return strings.Join(*v, ",")
+	}
+	return fmt.Sprint(ptr)
+}
+
+// redact masks a secret. URIs keep everything but their password so that
+// the log still shows where the server connects to.
+func redact(value string) string {
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		return u.String()
+	}
+	return "xxxxx"
+}
@@ -0,0 +1,304 @@
+// Package counters aggregates reaction counter increments in Redis and
+// writes them behind to MongoDB in batches.
+//
+// Every target with unflushed increments has a hash <name>:<id> holding one
+// field per reaction type and is a member of the set <name>:dirty. A flush
+// moves the hash to <name>:<id>:flushing, applies it and then deletes it, so
+// increments arriving meanwhile start a fresh hash and a failed flush is
+// retried with everything that piled up since. A target leaves the dirty set
+// only once its increments are applied and no new ones arrived, so a flush
+// that dies halfway loses nothing. It may apply the increments it was
+// working on a second time, though.
+package counters
+
+import (
+	"blogo/models"
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultBatchSize is how many targets a flush takes from the dirty set at
+// a time.
+const DefaultBatchSize = 100
+
+// ApplyFunc writes the increments of one target to the database. A target
+// that no longer exists should count as applied, or its increments are
+// retried forever.
+type ApplyFunc func(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error
+
+// FlushedFunc is told which targets a flush applied increments to, once
+// per batch.
+type FlushedFunc func(ctx context.Context, ids []primitive.ObjectID)
+
+// Buffer holds the unflushed counter increments of one kind of target.
+type Buffer struct {
+	client  *redis.Client
+	name    string
+	apply   ApplyFunc
+	flushed FlushedFunc
+
+	mu sync.Mutex
+	// applied holds the targets whose flushing hash was applied but could
+	// not be deleted. It must not be applied again.
+	applied map[string]bool
+}
+
+// NewBuffer creates a buffer whose keys start with name and which flushes
+// through apply.
+func NewBuffer(client *redis.Client, name string, apply ApplyFunc) *Buffer {
+	return &Buffer{client: client, name: name, apply: apply, applied: make(map[string]bool)}
+}
+
+// OnFlushed sets a function that is told about the targets of every flushed
+// batch, such as to drop cached copies of them or to reindex them. It must be set before the
+// first flush.
+func (b *Buffer) OnFlushed(flushed FlushedFunc) {
+	b.flushed = flushed
+}
+
+func (b *Buffer) key(id string) string {
+	return b.name + ":" + id
+}
+
+func (b *Buffer) flushingKey(id string) string {
+	return b.name + ":" + id + ":flushing"
+}
+
+func (b *Buffer) dirtyKey() string {
+	return b.name + ":dirty"
+}
+
+// Add records increments of the counters of a target.
+func (b *Buffer) Add(id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	pipe := b.client.TxPipeline()
+	for reactionType, delta := range deltas {
+		pipe.HIncrBy(b.key(id.Hex()), string(reactionType), delta)
+	}
+	pipe.SAdd(b.dirtyKey(), id.Hex())
+	_, err := pipe.Exec()
+	return err
+}
+
+// Pending returns the increments of targets that are not in the database
+// yet, keyed by target. Targets without any are left out.
+func (b *Buffer) Pending(ids ...primitive.ObjectID) (map[primitive.ObjectID]map[models.ReactionType]int64, error) {
+	pending := make(map[primitive.ObjectID]map[models.ReactionType]int64)
+	if len(ids) == 0 {
+		return pending, nil
+	}
+
+	pipe := b.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, 2*len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipe.HGetAll(b.key(id.Hex())), pipe.HGetAll(b.flushingKey(id.Hex())))
+	}
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		deltas := make(map[models.ReactionType]int64)
+		for _, cmd := range cmds[2*i : 2*i+2] {
+			for field, value := range cmd.Val() {
+				n, _ := strconv.ParseInt(value, 10, 64)
+				deltas[models.ReactionType(field)] += n
+			}
+		}
+		if len(deltas) > 0 {
+			pending[id] = deltas
+		}
+	}
+	return pending, nil
+}
+
+// Discard drops the unflushed increments of targets, such as ones that are
+// deleted for good.
+func (b *Buffer) Discard(ids ...primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, 2*len(ids))
+	members := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, b.key(id.Hex()), b.flushingKey(id.Hex()))
+		members = append(members, id.Hex())
+	}
+	pipe := b.client.TxPipeline()
+	pipe.SRem(b.dirtyKey(), members...)
+	pipe.Del(keys...)
+	_, err := pipe.Exec()
+	return err
+}
+
+// takeScript adds the hash KEYS[1] to KEYS[2], deletes KEYS[1] and returns
+// the fields and values of KEYS[2].
+var takeScript = redis.NewScript(`
+local fields = redis.call('HGETALL', KEYS[1])
+for i = 1, #fields, 2 do
+	redis.call('HINCRBY', KEYS[2], fields[i], fields[i + 1])
+end
+redis.call('DEL', KEYS[1])
+return redis.call('HGETALL', KEYS[2])
+`)
+
+// finishScript deletes the applied hash KEYS[2] and takes ARGV[1] out of
+// the dirty set KEYS[3], unless increments arrived in KEYS[1] meanwhile.
+var finishScript = redis.NewScript(`
+redis.call('DEL', KEYS[2])
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('SREM', KEYS[3], ARGV[1])
+end
+return 0
+`)
+
+// Flush applies the increments of up to batchSize targets and returns how
+// many it took. Targets that fail stay for the next flush.
+func (b *Buffer) Flush(ctx context.Context, batchSize int) (int, error) {
+	ids, err := b.client.SRandMemberN(b.dirtyKey(), int64(batchSize)).Result()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	return len(ids), b.flushBatch(ctx, ids)
+}
+
+// FlushAll flushes every target that was dirty when it started. Targets
+// that fail stay for the next flush, and the first error is returned.
+func (b *Buffer) FlushAll(ctx context.Context) error {
+	ids, err := b.client.SMembers(b.dirtyKey()).Result()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for start := 0; start < len(ids); start += DefaultBatchSize {
+		end := start + DefaultBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := b.flushBatch(ctx, ids[start:end]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// flushBatch flushes the targets ids, going on past failures, and returns
+// the first error.
+func (b *Buffer) flushBatch(ctx context.Context, ids []string) error {
+	var firstErr error
+	flushed := make([]primitive.ObjectID, 0, len(ids))
+	for _, hex := range ids {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			// not ours, drop it
+			b.client.SRem(b.dirtyKey(), hex)
+			continue
+		}
+		applied, err := b.flushOne(ctx, id)
+		if applied {
+			flushed = append(flushed, id)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(flushed) > 0 && b.flushed != nil {
+		b.flushed(ctx, flushed)
+	}
+	return firstErr
+}
+
+// flushOne applies the increments of one target and reports whether there
+// were any.
+func (b *Buffer) flushOne(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	hex := id.Hex()
+	if b.wasApplied(hex) {
+		// the last flush applied the hash already, only drop it now
+		if err := b.finish(hex); err != nil {
+			return false, err
+		}
+		b.setApplied(hex, false)
+	}
+
+	values, err := takeScript.Run(b.client, []string{b.key(hex), b.flushingKey(hex)}).Result()
+	if err != nil {
+		return false, err
+	}
+	fields, _ := values.([]interface{})
+	deltas := make(map[models.ReactionType]int64)
+	for i := 0; i+1 < len(fields); i += 2 {
+		field, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		n, _ := strconv.ParseInt(value, 10, 64)
+		if n != 0 {
+			deltas[models.ReactionType(field)] = n
+		}
+	}
+
+	if len(deltas) > 0 {
+		if err := b.apply(ctx, id, deltas); err != nil {
+			return false, err
+		}
+	}
+	if err := b.finish(hex); err != nil {
+		b.setApplied(hex, true)
+		return len(deltas) > 0, err
+	}
+	return len(deltas) > 0, nil
+}
+
+func (b *Buffer) finish(hex string) error {
+	return finishScript.Run(b.client, []string{b.key(hex), b.flushingKey(hex), b.dirtyKey()}, hex).Err()
+}
+
+func (b *Buffer) wasApplied(hex string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.applied[hex]
+}
+
+func (b *Buffer) setApplied(hex string, applied bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if applied {
+		b.applied[hex] = true
+	} else {
+		delete(b.applied, hex)
+	}
+}
+
+// Run flushes buffers every interval until ctx is done, then flushes them
+// one last time and returns. Nil buffers are skipped.
+func Run(ctx context.Context, interval time.Duration, buffers ...*Buffer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			flushAll(ctx, buffers)
+		case <-ctx.Done():
+			flushAll(context.Background(), buffers)
+			return
+		}
+	}
+}
+
+func flushAll(ctx context.Context, buffers []*Buffer) {
+	for _, b := range buffers {
+		if b == nil {
+			continue
+		}
+		if err := b.FlushAll(ctx); err != nil {
+			slog.Error("Flush counters failed", "counters", b.name, "error", err)
+		}
+	}
+}
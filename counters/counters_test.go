@@ -0,0 +1,274 @@
+package counters
+
+import (
+	"blogo/models"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sink is an ApplyFunc recording what was applied. It fails while err is
+// set.
+type sink struct {
+	mu      sync.Mutex
+	applied map[primitive.ObjectID]map[models.ReactionType]int64
+	calls   int
+	err     error
+}
+
+func newSink() *sink {
+	return &sink{applied: make(map[primitive.ObjectID]map[models.ReactionType]int64)}
+}
+
+func (s *sink) apply(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+	if s.applied[id] == nil {
+		s.applied[id] = make(map[models.ReactionType]int64)
+	}
+	for reactionType, delta := range deltas {
+		s.applied[id][reactionType] += delta
+	}
+	return nil
+}
+
+func newTestBuffer(t *testing.T, apply ApplyFunc) (*Buffer, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewBuffer(client, "counters:test", apply), server
+}
+
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	b, server := newTestBuffer(t, s.apply)
+	id := primitive.NewObjectID()
+
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1, models.ReactionFunny: 1})
+	b.Add(id, map[models.ReactionType]int64{models.ReactionFunny: -1})
+
+	pending, err := b.Pending(id, primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[id][models.ReactionLike] != 2 || pending[id][models.ReactionFunny] != 0 {
+		t.Errorf("Pending = %v", pending)
+	}
+
+	n, err := b.Flush(ctx, DefaultBatchSize)
+	if err != nil || n != 1 {
+		t.Fatalf("Flush = %d, %v, want 1, nil", n, err)
+	}
+	if got := s.applied[id]; len(got) != 1 || got[models.ReactionLike] != 2 {
+		t.Errorf("applied %v, want like: 2 without zero counters", got)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after a flush: %v", keys)
+	}
+	if pending, _ := b.Pending(id); len(pending) != 0 {
+		t.Errorf("Pending after a flush = %v", pending)
+	}
+}
+
+func TestFlushRetries(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	b, _ := newTestBuffer(t, s.apply)
+	id := primitive.NewObjectID()
+
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+	s.err = errors.New("database down")
+	if _, err := b.Flush(ctx, DefaultBatchSize); err == nil {
+		t.Fatal("Flush hides the error of apply")
+	}
+
+	// increments arriving after the failure join the retry, and reads
+	// still see all of them
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+	if pending, _ := b.Pending(id); pending[id][models.ReactionLike] != 2 {
+		t.Errorf("Pending after a failed flush = %v", pending)
+	}
+
+	s.err = nil
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.applied[id][models.ReactionLike]; got != 2 {
+		t.Errorf("applied %d likes, want 2", got)
+	}
+}
+
+func TestFlushBatches(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	b, _ := newTestBuffer(t, s.apply)
+	for i := 0; i < 5; i++ {
+		b.Add(primitive.NewObjectID(), map[models.ReactionType]int64{models.ReactionLike: 1})
+	}
+
+	if n, err := b.Flush(ctx, 2); err != nil || n != 2 {
+		t.Errorf("first Flush = %d, %v, want 2, nil", n, err)
+	}
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.applied) != 5 {
+		t.Errorf("applied %d targets, want 5", len(s.applied))
+	}
+	if n, err := b.Flush(ctx, 2); err != nil || n != 0 {
+		t.Errorf("Flush of an empty buffer = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestFlushAllPastFailures(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	broken := primitive.NewObjectID()
+	b, _ := newTestBuffer(t, func(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+		if id == broken {
+			return errors.New("document too large")
+		}
+		return s.apply(ctx, id, deltas)
+	})
+
+	// more than one batch, so that the failure does not end the flush
+	ids := make([]primitive.ObjectID, 2*DefaultBatchSize)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+		b.Add(ids[i], map[models.ReactionType]int64{models.ReactionLike: 1})
+	}
+	b.Add(broken, map[models.ReactionType]int64{models.ReactionLike: 1})
+
+	if err := b.FlushAll(ctx); err == nil {
+		t.Fatal("FlushAll hides the error of apply")
+	}
+	if len(s.applied) != len(ids) {
+		t.Errorf("applied %d targets, want %d", len(s.applied), len(ids))
+	}
+	if pending, _ := b.Pending(broken); pending[broken][models.ReactionLike] != 1 {
+		t.Errorf("failed target is not kept for the next flush: %v", pending)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	b, _ := newTestBuffer(t, s.apply)
+	gone, kept := primitive.NewObjectID(), primitive.NewObjectID()
+	b.Add(gone, map[models.ReactionType]int64{models.ReactionLike: 1})
+	b.Add(kept, map[models.ReactionType]int64{models.ReactionLike: 1})
+
+	if err := b.Discard(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := b.Pending(gone); len(pending[gone]) != 0 {
+		t.Errorf("Pending after Discard = %v", pending)
+	}
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.applied[gone]; ok || s.applied[kept][models.ReactionLike] != 1 {
+		t.Errorf("applied %v", s.applied)
+	}
+}
+
+func TestFlushKeepsIncrementsArrivingMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	var b *Buffer
+	id := primitive.NewObjectID()
+	b, server := newTestBuffer(t, func(ctx context.Context, target primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+		if s.calls == 0 {
+			b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+		}
+		return s.apply(ctx, target, deltas)
+	})
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := server.SIsMember("counters:test:dirty", id.Hex()); !ok {
+		t.Fatal("target with new increments left the dirty set")
+	}
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.applied[id][models.ReactionLike]; got != 2 {
+		t.Errorf("applied %d likes, want 2", got)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after the flushes: %v", keys)
+	}
+}
+
+func TestFlushAfterLostConnection(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	var server *miniredis.Miniredis
+	b, server := newTestBuffer(t, func(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+		// Redis goes away after the database took the increments
+		defer server.Close()
+		return s.apply(ctx, id, deltas)
+	})
+	id := primitive.NewObjectID()
+	b.Add(id, map[models.ReactionType]int64{models.ReactionLike: 1})
+
+	if err := b.FlushAll(ctx); err == nil {
+		t.Fatal("FlushAll hides the lost connection")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	b.apply = s.apply
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.applied[id][models.ReactionLike]; got != 1 {
+		t.Errorf("applied %d likes, want 1", got)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after the flushes: %v", keys)
+	}
+}
+
+func TestOnFlushed(t *testing.T) {
+	ctx := context.Background()
+	s := newSink()
+	b, _ := newTestBuffer(t, s.apply)
+	var batches [][]primitive.ObjectID
+	b.OnFlushed(func(_ context.Context, ids []primitive.ObjectID) { batches = append(batches, ids) })
+
+	for i := 0; i < DefaultBatchSize+1; i++ {
+		b.Add(primitive.NewObjectID(), map[models.ReactionType]int64{models.ReactionLike: 1})
+	}
+	// increments that cancel out apply nothing
+	b.Add(primitive.NewObjectID(), map[models.ReactionType]int64{models.ReactionLike: 0})
+
+	if err := b.FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, ids := range batches {
+		n += len(ids)
+	}
+	if len(batches) != 2 || n != DefaultBatchSize+1 {
+		t.Errorf("got %d batches of %d targets, want 2 of %d", len(batches), n, DefaultBatchSize+1)
+	}
+}
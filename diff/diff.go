@@ -0,0 +1,212 @@
+// Package diff computes line based differences between two texts.
+package diff
+
+import "strings"
+
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+// Line is a single line of a diff together with the operation that turns
+// the old text into the new one.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxCost bounds the number of differing lines the search for the shortest
+// edit script explores in a part of the texts. Parts that differ more are
+// replaced as a whole, which keeps the time spent on huge texts in check.
+const maxCost = 1000
+
+// Lines returns an edit script that turns a into b. It is computed with
+// Myers' algorithm in linear space and is the shortest script unless a part
+// of the texts differs in more than maxCost lines.
+func Lines(a, b string) []Line {
+	x := split(a)
+	y := split(b)
+
+	// lines are compared as numbers, equal lines get the same number
+	numbers := make(map[string]int, len(x)+len(y))
+	number := func(lines []string) []int {
+		ns := make([]int, len(lines))
+		for i, line := range lines {
+			n, ok := numbers[line]
+			if !ok {
+				n = len(numbers)
+				numbers[line] = n
+			}
+			ns[i] = n
+		}
+		return ns
+	}
+
+	d := differ{x: x, y: y, xn: number(x), yn: number(y)}
+	d.lines = make([]Line, 0, len(x)+len(y))
+	d.compare(0, len(x), 0, len(y))
+	return d.lines
+}
+
+type differ struct {
+	x, y   []string
+	xn, yn []int
+	lines  []Line
+}
+
+// compare appends the edit script that turns x[xlo:xhi] into y[ylo:yhi].
+func (d *differ) compare(xlo, xhi, ylo, yhi int) {
+	for xlo < xhi && ylo < yhi && d.xn[xlo] == d.yn[ylo] {
+		d.lines = append(d.lines, Line{Op: OpEqual, Text: d.x[xlo]})
+		xlo++
+		ylo++
+	}
+	suffix := 0
+	for xlo < xhi && ylo < yhi && d.xn[xhi-1] == d.yn[yhi-1] {
+		xhi--
+		yhi--
+		suffix++
+	}
+
+	xmid, ymid, ok := 0, 0, false
+	if xlo < xhi && ylo < yhi {
+		xmid, ymid, ok = bisect(d.xn[xlo:xhi], d.yn[ylo:yhi])
+	}
+	if ok {
+		d.compare(xlo, xlo+xmid, ylo, ylo+ymid)
+		d.compare(xlo+xmid, xhi, ylo+ymid, yhi)
+	} else {
+		for i := xlo; i < xhi; i++ {
+			d.lines = append(d.lines, Line{Op: OpDelete, Text: d.x[i]})
+		}
+		for j := ylo; j < yhi; j++ {
+			d.lines = append(d.lines, Line{Op: OpInsert, Text: d.y[j]})
+		}
+	}
+
+	for i := xhi; i < xhi+suffix; i++ {
+		d.lines = append(d.lines, Line{Op: OpEqual, Text: d.x[i]})
+	}
+}
+
+// bisect walks the shortest edit paths from both ends of x and y at once
+// and returns the point where they meet, which splits the problem in two.
+// It gives up once the paths take more than maxCost edits.
+func bisect(x, y []int) (int, int, bool) {
+	n, m := len(x), len(y)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// v1[offset+k] and v2[offset+k] are the furthest x reached on diagonal
+	// k from the start and from the end
+	v1 := make([]int, 2*maxD+2)
+	v2 := make([]int, 2*maxD+2)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+	delta := n - m
+	// with an odd delta the forward path is the one to run into the other
+	front := delta%2 != 0
+	// diagonals that ran off the edge are skipped from then on
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for d := 0; d < maxD && d <= maxCost; d++ {
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -d || (k1 != d && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && x[x1] == y[y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < len(v2) && v2[k2Offset] != -1 {
+					if x1 >= n-v2[k2Offset] {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -d || (k2 != d && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && x[n-x2-1] == y[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < len(v1) && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					if x1 >= n-x2 {
+						return x1, offset + x1 - k1Offset, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Strings returns the elements removed from a and the elements added in b,
+// ignoring order.
+func Strings(a, b []string) (removed, added []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	removed = make([]string, 0)
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	added = make([]string, 0)
+	for _, s := range b {
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	return removed, added
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"empty", "", "", []Line{}},
+		{"insert all", "", "a\nb", []Line{{OpInsert, "a"}, {OpInsert, "b"}}},
+		{"delete all", "a\nb", "", []Line{{OpDelete, "a"}, {OpDelete, "b"}}},
+		{"equal", "a\nb", "a\nb", []Line{{OpEqual, "a"}, {OpEqual, "b"}}},
+		{"change middle", "a\nb\nc", "a\nx\nc", []Line{{OpEqual, "a"}, {OpDelete, "b"}, {OpInsert, "x"}, {OpEqual, "c"}}},
+		{"insert middle", "a\nc", "a\nb\nc", []Line{{OpEqual, "a"}, {OpInsert, "b"}, {OpEqual, "c"}}},
+		{"move", "a\nb\nc", "b\nc\na", []Line{{OpDelete, "a"}, {OpEqual, "b"}, {OpEqual, "c"}, {OpInsert, "a"}}},
+	}
+	for _, test := range tests {
+		if got := Lines(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Lines(%q, %q) = %v, want %v", test.name, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// apply rebuilds both texts from a diff.
+func apply(lines []Line) (a, b string) {
+	var as, bs []string
+	for _, line := range lines {
+		if line.Op != OpInsert {
+			as = append(as, line.Text)
+		}
+		if line.Op != OpDelete {
+			bs = append(bs, line.Text)
+		}
+	}
+	return strings.Join(as, "\n"), strings.Join(bs, "\n")
+}
+
+func TestLinesShortest(t *testing.T) {
+	a := "the\nquick\nbrown\nfox\njumps\nover\nthe\nlazy\ndog"
+	b := "the\nslow\nbrown\nfox\njumps\nover\na\nlazy\ncat"
+	lines := Lines(a, b)
+	if gotA, gotB := apply(lines); gotA != a || gotB != b {
+		t.Fatalf("diff rebuilds %q and %q", gotA, gotB)
+	}
+	edits := 0
+	for _, line := range lines {
+		if line.Op != OpEqual {
+			edits++
+		}
+	}
+	if edits != 6 {
+		t.Errorf("diff has %d edits, want 6", edits)
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	// far more lines than a quadratic table could hold
+	a := strings.Repeat("\n", 100000)
+	var b strings.Builder
+	for i := 0; i < 50000; i++ {
+		b.WriteString(strings.Repeat("x", i%7))
+		b.WriteString("\n")
+	}
+	lines := Lines(a, b.String())
+	if gotA, gotB := apply(lines); gotA != a || gotB != b.String() {
+		t.Fatal("diff of large texts does not rebuild them")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	removed, added := Strings([]string{"go", "web", "db"}, []string{"db", "go", "api"})
+	if !reflect.DeepEqual(removed, []string{"web"}) || !reflect.DeepEqual(added, []string{"api"}) {
+		t.Errorf("Strings = %v, %v, want [web], [api]", removed, added)
+	}
+}
@@ -0,0 +1,256 @@
+// Package feed renders lists of posts as RSS 2.0, Atom 1.0 and JSON Feed 1.1
+// documents that readers can subscribe to.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Feed is a list of entries that is rendered the same way in every format.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the listing the feed mirrors and URL the feed itself.
+	Link string
+	URL  string
+	// Updated is when the newest change to an entry was made.
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is one post of a feed.
+type Entry struct {
+	// ID identifies the entry for good, even if Link changes.
+	ID        string
+	Link      string
+	Title     string
+	Author    string
+	Tags      []string
+	HTML      string
+	Published time.Time
+	Updated   time.Time
+}
+
+// Format is one of the supported feed formats, named by the extension of
+// its path.
+type Format string
+
+const (
+	RSS  Format = "rss"
+	Atom Format = "atom"
+	JSON Format = "json"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{RSS, Atom, JSON}
+
+// ContentType returns the media type documents of format are served with.
+func (format Format) ContentType() string {
+	switch format {
+	case RSS:
+		return "application/rss+xml; charset=utf-8"
+	case Atom:
+		return "application/atom+xml; charset=utf-8"
+	}
+	return "application/feed+json; charset=utf-8"
+}
+
+// Render encodes f in format.
+func (format Format) Render(f Feed) ([]byte, error) {
+	switch format {
+	case RSS:
+		return marshalXML(rss(f))
+	case Atom:
+		return marshalXML(atom(f))
+	case JSON:
+		return json.MarshalIndent(jsonFeed(f), "", "  ")
+	}
+	return nil, fmt.Errorf("unknown feed format %q", format)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title string  `xml:"title"`
+	Link  string  `xml:"link"`
+	GUID  rssGUID `xml:"guid"`
+	// RSS wants an email address as author, so the name goes to Dublin Core
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func rss(f Feed) rssDocument {
+	doc := rssDocument{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Self:        atomLink{Rel: "self", Type: RSS.ContentType(), Href: f.URL},
+			Items:       make([]rssItem, 0, len(f.Entries)),
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, entry := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: entry.ID == entry.Link, Value: entry.ID},
+			Creator:     entry.Author,
+			Categories:  entry.Tags,
+			PubDate:     entry.Published.UTC().Format(time.RFC1123Z),
+			Description: entry.HTML,
+		})
+	}
+	return doc
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func atom(f Feed) atomDocument {
+	doc := atomDocument{
+		ID:      f.URL,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: Atom.ContentType(), Href: f.URL},
+			{Rel: "alternate", Href: f.Link},
+		},
+		Entries: make([]atomEntry, 0, len(f.Entries)),
+	}
+	for _, entry := range f.Entries {
+		categories := make([]atomCategory, 0, len(entry.Tags))
+		for _, tag := range entry.Tags {
+			categories = append(categories, atomCategory{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:         entry.ID,
+			Title:      entry.Title,
+			Link:       atomLink{Rel: "alternate", Href: entry.Link},
+			Author:     atomAuthor{Name: entry.Author},
+			Categories: categories,
+			Published:  entry.Published.UTC().Format(time.RFC3339),
+			Updated:    entry.Updated.UTC().Format(time.RFC3339),
+			Content:    atomContent{Type: "html", Value: entry.HTML},
+		})
+	}
+	return doc
+}
+
+type jsonDocument struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func jsonFeed(f Feed) jsonDocument {
+	doc := jsonDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.URL,
+		Description: f.Description,
+		Items:       make([]jsonItem, 0, len(f.Entries)),
+	}
+	for _, entry := range f.Entries {
+		doc.Items = append(doc.Items, jsonItem{
+			ID:            entry.ID,
+			URL:           entry.Link,
+			Title:         entry.Title,
+			ContentHTML:   entry.HTML,
+			DatePublished: entry.Published.UTC().Format(time.RFC3339),
+			DateModified:  entry.Updated.UTC().Format(time.RFC3339),
+			Authors:       []jsonAuthor{{Name: entry.Author}},
+			Tags:          entry.Tags,
+		})
+	}
+	return doc
+}
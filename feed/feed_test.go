@@ -0,0 +1,121 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	updated := published.Add(time.Hour)
+	return Feed{
+		Title:       "Blogo",
+		Description: "The newest posts of Blogo",
+		Link:        "https://blogo.test/posts",
+		URL:         "https://blogo.test/feed",
+		Updated:     updated,
+		Entries: []Entry{{
+			ID:        "https://blogo.test/posts/1",
+			Link:      "https://blogo.test/posts/1",
+			Title:     "Hello & welcome",
+			Author:    "alice",
+			Tags:      []string{"go", "web"},
+			HTML:      "<p>Hi <em>there</em></p>",
+			Published: published,
+			Updated:   updated,
+		}},
+	}
+}
+
+func TestRSS(t *testing.T) {
+	data, err := RSS.Render(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("RSS lacks the XML header:\n%s", data)
+	}
+	var doc rssDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2.0" || doc.Channel.LastBuildDate != "Fri, 01 Mar 2024 12:00:00 +0000" {
+		t.Errorf("channel: got %+v", doc.Channel)
+	}
+	// encoding/xml cannot tell the two links apart when decoding
+	for _, link := range []string{
+		"<link>https://blogo.test/posts</link>",
+		`<link xmlns="http://www.w3.org/2005/Atom" rel="self" type="application/rss+xml; charset=utf-8" href="https://blogo.test/feed"></link>`,
+	} {
+		if !strings.Contains(string(data), link) {
+			t.Errorf("RSS lacks %s:\n%s", link, data)
+		}
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if item.Title != "Hello & welcome" || item.Description != "<p>Hi <em>there</em></p>" || item.Creator != "alice" ||
+		!item.GUID.IsPermaLink || item.PubDate != "Fri, 01 Mar 2024 11:00:00 +0000" || len(item.Categories) != 2 {
+		t.Errorf("item: got %+v", item)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	data, err := Atom.Render(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc atomDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != "https://blogo.test/feed" || doc.Updated != "2024-03-01T12:00:00Z" || len(doc.Links) != 2 {
+		t.Errorf("feed: got %+v", doc)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.Content.Type != "html" || entry.Content.Value != "<p>Hi <em>there</em></p>" || entry.Author.Name != "alice" ||
+		entry.Published != "2024-03-01T11:00:00Z" || len(entry.Categories) != 2 || entry.Categories[1].Term != "web" {
+		t.Errorf("entry: got %+v", entry)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := JSON.Render(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.FeedURL != "https://blogo.test/feed" || doc.HomePageURL != "https://blogo.test/posts" {
+		t.Errorf("feed: got %+v", doc)
+	}
+	if len(doc.Items) != 1 || doc.Items[0].ContentHTML != "<p>Hi <em>there</em></p>" || doc.Items[0].Authors[0].Name != "alice" {
+		t.Errorf("items: got %+v", doc.Items)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	f := testFeed()
+	f.Entries = nil
+	for _, format := range Formats {
+		data, err := format.Render(f)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if strings.Contains(string(data), "null") {
+			t.Errorf("%s: empty feed renders null:\n%s", format, data)
+		}
+	}
+	if _, err := Format("pdf").Render(f); err == nil {
+		t.Error("rendering an unknown format succeeded")
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/models"
+	"blogo/store"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminHandler struct {
+	users store.UserStore
+}
+
+func NewAdminHandler(users store.UserStore) *AdminHandler {
+	return &AdminHandler{
+		users: users,
+	}
+}
+
+// userSummary is what administrators see of an account.
+type userSummary struct {
+	UserID   primitive.ObjectID `json:"userID"`
+	Username string             `json:"username"`
+	Role     models.Role        `json:"role"`
+	Disabled bool               `json:"disabled"`
+}
+
+// swagger:operation GET /admin/users admin listUsers
+// List all user accounts
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '403':
+//     description: Signed in user is not an admin
+func (handler *AdminHandler) ListUsersHandler(c *gin.Context) error {
+	users, err := handler.users.ListUsers(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	summaries := make([]userSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, userSummary{
+			UserID:   user.UserID,
+			Username: user.Username,
+			Role:     user.EffectiveRole(),
+			Disabled: user.Disabled,
+		})
+	}
+	c.JSON(http.StatusOK, summaries)
+	return nil
+}
+
+// swagger:operation PUT /admin/users/{username}/role admin updateUserRole
+// Change the role of a user
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: username
+//     in: path
+//     required: true
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Unknown role or own account
+//   '403':
+//     description: Signed in user is not an admin
+//   '404':
+//     description: user not found
+func (handler *AdminHandler) UpdateUserRoleHandler(c *gin.Context) error {
+	var body struct {
+		Role models.Role `json:"role"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		return err
+	}
+	if !body.Role.Valid() {
+		return apierror.New(apierror.CodeBadRequest, "unknown role")
+	}
+
+	username, err := handler.otherUser(c)
+	if err != nil {
+		return err
+	}
+	err = handler.users.UpdateUserRole(c.Request.Context(), username, body.Role)
+	if err != nil {
+		return userError(err)
+	}
+	c.JSON(http.StatusOK, gin.H{"username": username, "role": body.Role})
+	return nil
+}
+
+// swagger:operation POST /admin/users/{username}/disable admin disableUser
+// Disable a user account so that it can no longer sign in
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Own account
+//   '403':
+//     description: Signed in user is not an admin
+//   '404':
+//     description: user not found
+func (handler *AdminHandler) DisableUserHandler(c *gin.Context) error {
+	return handler.setDisabled(c, true)
+}
+
+// swagger:operation POST /admin/users/{username}/enable admin enableUser
+// Enable a previously disabled user account
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Own account
+//   '403':
+//     description: Signed in user is not an admin
+//   '404':
+//     description: user not found
+func (handler *AdminHandler) EnableUserHandler(c *gin.Context) error {
+	return handler.setDisabled(c, false)
+}
+
+func (handler *AdminHandler) setDisabled(c *gin.Context, disabled bool) error {
+	username, err := handler.otherUser(c)
+	if err != nil {
+		return err
+	}
+	err = handler.users.SetUserDisabled(c.Request.Context(), username, disabled)
+	if err != nil {
+		return userError(err)
+	}
+	c.JSON(http.StatusOK, gin.H{"username": username, "disabled": disabled})
+	return nil
+}
+
+// otherUser returns the username path parameter. Admins may not change
+// their own account so that they cannot lock themselves out.
+func (handler *AdminHandler) otherUser(c *gin.Context) (string, error) {
+	username := c.Param("username")
+	if username == currentUsername(c) {
+		return "", apierror.New(apierror.CodeBadRequest, "cannot change your own account")
+	}
+	return username, nil
+}
+
+// userError names the user in a not found error.
+func userError(err error) error {
+	if err == store.ErrNotFound {
+		return apierror.New(apierror.CodeNotFound, "user not found")
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"blogo/models"
+	"net/http"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+
+	s.setRole(t, "bob", models.RoleReader)
+	if code, _ := bob.do("POST", "/posts", `{"postTitle":"Reader"}`); code != http.StatusForbidden {
+		t.Errorf("POST /posts as a reader: got %d, want 403", code)
+	}
+	bob.mustDo("POST", "/comments/"+postID, `{"commentContent":"Readers comment"}`)
+	if code, _ := bob.do("GET", "/admin/users", ""); code != http.StatusForbidden {
+		t.Errorf("GET /admin/users as a reader: got %d, want 403", code)
+	}
+
+	s.setRole(t, "bob", models.RoleModerator)
+	bob.mustDo("DELETE", "/posts/"+postID, "")
+
+	// new accounts may not write posts until an admin promotes them
+	carol := s.client(t)
+	carol.mustDo("POST", "/signup", `{"username":"carol","password":"passw0rd!"}`)
+	if code, _ := carol.do("POST", "/posts", `{"postTitle":"Newcomer"}`); code != http.StatusForbidden {
+		t.Errorf("POST /posts after signing up: got %d, want 403", code)
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	s := newTestServer(t)
+	root := s.client(t)
+	root.signUp("root")
+	s.setRole(t, "root", models.RoleAdmin)
+	alice := s.client(t)
+	alice.signUp("alice")
+
+	var users []userSummary
+	decode(t, root.mustDo("GET", "/admin/users", ""), &users)
+	if len(users) != 2 {
+		t.Fatalf("GET /admin/users: got %+v", users)
+	}
+
+	if code, _ := root.do("PUT", "/admin/users/alice/role", `{"role":"owner"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown role: got %d, want 400", code)
+	}
+	if code, _ := root.do("PUT", "/admin/users/root/role", `{"role":"reader"}`); code != http.StatusBadRequest {
+		t.Errorf("changing the own role: got %d, want 400", code)
+	}
+	if code, _ := root.do("PUT", "/admin/users/nobody/role", `{"role":"reader"}`); code != http.StatusNotFound {
+		t.Errorf("unknown user: got %d, want 404", code)
+	}
+	root.mustDo("PUT", "/admin/users/alice/role", `{"role":"reader"}`)
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Hello"}`); code != http.StatusForbidden {
+		t.Errorf("POST /posts after demotion: got %d, want 403", code)
+	}
+
+	postID := field(t, root.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	root.mustDo("POST", "/admin/users/alice/disable", "")
+	if code, _ := alice.do("POST", "/comments/"+postID, `{"commentContent":"Hi"}`); code != http.StatusForbidden {
+		t.Errorf("request of a disabled account: got %d, want 403", code)
+	}
+	if code, _ := s.client(t).do("POST", "/signin", `{"username":"alice","password":"passw0rd!"}`); code != http.StatusForbidden {
+		t.Errorf("sign in of a disabled account: got %d, want 403", code)
+	}
+	root.mustDo("POST", "/admin/users/alice/enable", "")
+	s.client(t).mustDo("POST", "/signin", `{"username":"alice","password":"passw0rd!"}`)
+}
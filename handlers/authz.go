@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userKey is the gin context key under which AuthMiddileware stores the
+// signed in models.User.
+const userKey = "user"
+
+// currentUser returns the user loaded by AuthMiddileware.
+func currentUser(c *gin.Context) (models.User, bool) {
+	value, ok := c.Get(userKey)
+	if !ok {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
+
+// currentUsername returns the name of the user loaded by AuthMiddileware
+// or OptionalAuthMiddleware, or an empty string when none was.
+func currentUsername(c *gin.Context) string {
+	if user, ok := currentUser(c); ok {
+		return user.Username
+	}
+	return ""
+}
+
+// canModerate reports whether the signed in user may modify content of
+// other users.
+func canModerate(c *gin.Context) bool {
+	user, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+	role := user.EffectiveRole()
+	return role == models.RoleModerator || role == models.RoleAdmin
+}
+
+// authorize reports whether the signed in user may modify content owned by
+// owner, which is the case for the owner, moderators and administrators. It
+// returns a forbidden error when they may not.
+func authorize(c *gin.Context, owner string) error {
+	username := currentUsername(c)
+	if username != "" && (username == owner || canModerate(c)) {
+		return nil
+	}
+	return apierror.New(apierror.CodeForbidden, "only the author or a moderator can modify this content")
+}
+
+// canView reports whether the signed in user may see post. Posts that are
+// not visible yet are only shown to their author, and posts in the trash to
+// no one.
+func canView(c *gin.Context, post models.Post) bool {
+	if post.Trash != nil {
+		return false
+	}
+	if post.EffectiveStatus().Visible() {
+		return true
+	}
+	username := currentUsername(c)
+	return username != "" && username == post.Username
+}
+
+// RequireRole only lets through users holding one of roles. It must run
+// after AuthMiddileware.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			apierror.Abort(c, apierror.New(apierror.CodeUnauthenticated, "not signed in"))
+			return
+		}
+		role := user.EffectiveRole()
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		apierror.Abort(c, apierror.New(apierror.CodeForbidden, "insufficient role"))
+	}
+}
+
+// Username returns the name of the signed in user, or an empty string. It
+// is meant for loggers and metrics outside the handlers.
+func Username(c *gin.Context) string {
+	return currentUsername(c)
+}
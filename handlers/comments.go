@@ -1,31 +1,49 @@
 package handlers
 
 import (
+	"blogo/apierror"
+	"blogo/counters"
 	"blogo/models"
-	"log"
+	"blogo/store"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/net/context"
 )
 
 type CommentsHandler struct {
-	ctx         context.Context
-	collection  *mongo.Collection
+	comments    store.CommentStore
+	posts       store.PostStore
+	reactions   store.ReactionStore
 	redisClient *redis.Client
+	counters    *counters.Buffer
 }
 
-func NewCommentsHandlers(ctx context.Context, collection *mongo.Collection, redisClient *redis.Client) *CommentsHandler {
-	return &CommentsHandler{
-		ctx:         ctx,
-		collection:  collection,
+// NewCommentsHandlers creates the comment handlers. Reactions are counted
+// in Redis and flushed by the flusher of Counters unless redisClient is nil.
+func NewCommentsHandlers(comments store.CommentStore, posts store.PostStore, reactions store.ReactionStore, redisClient *redis.Client) *CommentsHandler {
+	handler := &CommentsHandler{
+		comments:    comments,
+		posts:       posts,
+		reactions:   reactions,
 		redisClient: redisClient,
 	}
+	if redisClient != nil {
+		handler.counters = counters.NewBuffer(redisClient, commentsCountersName, handler.flushReactions)
+	}
+	return handler
+}
+
+const commentsCountersName = "counters:comments"
+
+// Counters returns the buffer of unflushed reaction counts, or nil when
+// there is no Redis.
+func (handler *CommentsHandler) Counters() *counters.Buffer {
+	return handler.counters
 }
 
 // swagger:operation GET /comments/{postid} comment listCommentsToPosts
@@ -39,107 +57,382 @@ func NewCommentsHandlers(ctx context.Context, collection *mongo.Collection, redi
 //     description: ID of the post
 //     required: true
 //     type: string
+//   - name: view
+//     in: query
+//     description: flat (default) or tree to nest replies under their parents
+//     type: string
+//   - name: limit
+//     in: query
+//     description: Top level comments per page in tree view
+//     type: integer
+//   - name: offset
+//     in: query
+//     description: Top level comments to skip in tree view
+//     type: integer
 // responses:
 //   '200':
 //     description: Success operation
+//   '400':
+//     description: Invalid post ID, view, limit or offset
 //   '404':
 //	   description: Invalid posts
-func (handler *CommentsHandler) ListCommentsToPostHandler(c *gin.Context) {
-	postIDString := c.Param("postid") // get post id
-	postID, err := primitive.ObjectIDFromHex(postIDString)
-	log.Println(postID)
+func (handler *CommentsHandler) ListCommentsToPostHandler(c *gin.Context) error {
+	postID, err := paramID(c, "postid")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return err
+	}
+	if err := handler.checkPost(c, postID); err != nil {
+		return err
 	}
 
-	cur, err := handler.collection.Find(handler.ctx, bson.M{
-		"commentToID": postID,
-	})
+	switch c.DefaultQuery("view", "flat") {
+	case "flat":
+	case "tree":
+		return handler.listCommentThreads(c, postID)
+	default:
+		return apierror.New(apierror.CodeBadRequest, "view must be flat or tree")
+	}
+
+	comments, err := handler.comments.ListCommentsToPost(c.Request.Context(), postID)
+	if err != nil {
+		return err
+	}
+	handler.mergePending(c.Request.Context(), commentPointers(comments)...)
+	c.JSON(http.StatusOK, hideTrashed(comments, comments))
+	return nil
+}
+
+// checkPost makes sure the post comments are listed or written to exists
+// and may be seen by the signed in user, like loadPost does.
+func (handler *CommentsHandler) checkPost(c *gin.Context, postID primitive.ObjectID) error {
+	post, err := handler.posts.GetPost(c.Request.Context(), postID)
+	if err != nil {
+		return err
+	}
+	if !canView(c, post) {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// commentNode is a comment with its replies in tree view.
+type commentNode struct {
+	models.Comment
+	Replies []*commentNode `json:"replies"`
+}
+
+// listCommentThreads writes one page of top level comments of a post with
+// their replies nested below them.
+func (handler *CommentsHandler) listCommentThreads(c *gin.Context, postID primitive.ObjectID) error {
+	limit, err := intQuery(c, "limit", 1, store.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	if limit == 0 {
+		limit = store.DefaultPageSize
+	}
+	offset, err := intQuery(c, "offset", 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+
+	threads, err := handler.comments.ListCommentThreads(c.Request.Context(), postID, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return err
+	}
+	handler.mergePending(c.Request.Context(), append(commentPointers(threads.Roots), commentPointers(threads.Replies)...)...)
+	threads.Roots = hideTrashed(threads.Roots, threads.Replies)
+	threads.Replies = hideTrashed(threads.Replies, threads.Replies)
+
+	nodes := make(map[primitive.ObjectID]*commentNode)
+	roots := make([]*commentNode, 0, len(threads.Roots))
+	for _, comment := range threads.Roots {
+		node := &commentNode{Comment: comment, Replies: []*commentNode{}}
+		nodes[comment.CommentID] = node
+		roots = append(roots, node)
 	}
-	comments := make([]models.Comment, 0)
-	for cur.Next(handler.ctx) {
-		var comment models.Comment
-		cur.Decode(&comment)
-		comments = append(comments, comment)
+	// replies come oldest first, so a parent is always seen before its replies
+	for _, comment := range threads.Replies {
+		parent, ok := nodes[*comment.ParentID]
+		if !ok {
+			continue
+		}
+		node := &commentNode{Comment: comment, Replies: []*commentNode{}}
+		nodes[comment.CommentID] = node
+		parent.Replies = append(parent.Replies, node)
 	}
-	c.JSON(http.StatusOK, comments)
+
+	c.JSON(http.StatusOK, gin.H{"threads": roots, "total": threads.Total})
+	return nil
 }
 
 // swagger:operation POST /comments/:postid comment createCommentToPost
-// Create a comment to a post
+// Create a comment to a post, or a reply to a comment when parentID is set
 // ---
 // produce:
 // - application/json
 // responses:
 //   '200':
 //     description: Success operation
+//   '400':
+//     description: Invalid comment or post ID, or parent is deleted, on another post or too deeply nested
 //   '404':
-//	   description: Invalid posts
-func (handler *CommentsHandler) CreateCommentToPostHandler(c *gin.Context) {
+//	   description: Post or parent comment not found
+func (handler *CommentsHandler) CreateCommentToPostHandler(c *gin.Context) error {
 	var comment models.Comment
-	if err := c.ShouldBindJSON(&comment); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := bindJSON(c, &comment); err != nil {
+		return err
 	}
-	postIDString := c.Param("postid")
-	postID, err := primitive.ObjectIDFromHex(postIDString)
-
+	postID, err := paramID(c, "postid")
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return err
+	}
+	if err := handler.checkPost(c, postID); err != nil {
+		return err
 	}
 
+	comment.Username = currentUsername(c) // the session decides who the author is
 	comment.NumOfThumb = 0
+	comment.Reactions = nil
 	comment.CommentID = primitive.NewObjectID()
 	comment.CommentToID = postID
 	comment.CreatedTime = time.Now()
+	comment.Deleted = false
+	comment.Trash = nil
+	comment.RootID = nil
+	comment.Depth = 0
 
-	// TODO: use redis
+	if comment.ParentID != nil {
+		parent, err := handler.comments.GetComment(c.Request.Context(), *comment.ParentID)
+		if err == store.ErrNotFound {
+			return apierror.New(apierror.CodeNotFound, "parent comment not found")
+		} else if err != nil {
+			return err
+		}
+		if parent.CommentToID != postID {
+			return apierror.New(apierror.CodeBadRequest, "parent comment belongs to another post")
+		}
+		if parent.Deleted || parent.Trash != nil {
+			return apierror.New(apierror.CodeBadRequest, "cannot reply to a deleted comment")
+		}
+		if parent.Depth+1 > models.MaxCommentDepth {
+			return apierror.New(apierror.CodeBadRequest, "replies may be nested at most %d levels deep", models.MaxCommentDepth)
+		}
 
-	_, err = handler.collection.InsertOne(handler.ctx, comment)
+		comment.Depth = parent.Depth + 1
+		comment.RootID = parent.RootID
+		if comment.RootID == nil {
+			comment.RootID = &parent.CommentID
+		}
+	}
+
+	// TODO: use redis
 
+	err = handler.comments.InsertComment(c.Request.Context(), comment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
 	c.JSON(http.StatusOK, comment)
+	return nil
 }
 
 // swagger:operation POST /comments/thumbup/{commentid} comment commentThumbup
-// Create a comment to a post
+// Like a comment. Deprecated in favour of PUT /comments/{commentid}/reactions.
 // ---
 // produce:
 // - application/json
 // responses:
 //   '200':
 //     description: Success operation
+//   '400':
+//     description: Invalid comment ID
 //   '404':
-//	   description: Invalid posts
-func (handler *CommentsHandler) CommentThumbupHandler(c *gin.Context) {
-	commentIDString := c.Param("commentid")
-	commentID, _ := primitive.ObjectIDFromHex(commentIDString)
-
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"_id": commentID,
+//	   description: comment with provided ID not found
+func (handler *CommentsHandler) CommentThumbupHandler(c *gin.Context) error {
+	return handler.reactToComment(c, models.ReactionLike, func(models.Comment) {
+		c.JSON(http.StatusOK, gin.H{"thumbupResult": "success"})
 	})
-	log.Println(commentID)
-	var comment models.Comment
-	err := cur.Decode(&comment)
-	if err != nil { // decode error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+}
+
+// swagger:operation PUT /comments/{commentid} comment updateComment
+// Change the content of a comment
+// ---
+// produce:
+// - application/json
+// responses:
+//   '200':
+//     description: Success operation
+//   '400':
+//     description: Invalid comment ID or body
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: comment with provided ID not found
+func (handler *CommentsHandler) UpdateCommentHandler(c *gin.Context) error {
+	comment, err := handler.loadComment(c)
+	if err != nil {
+		return err
+	}
+	if err := authorize(c, comment.Username); err != nil {
+		return err
 	}
 
-	num := comment.NumOfThumb
-	_, err = handler.collection.UpdateByID(handler.ctx, commentID, bson.M{
-		"$set": bson.D{{"postNumOfThumb", num + 1}},
-	})
+	var update models.Comment
+	if err := bindJSON(c, &update); err != nil {
+		return err
+	}
+
+	err = handler.comments.UpdateCommentContent(c.Request.Context(), comment.CommentID, update.Content)
+	if err != nil {
+		return err
+	}
+
+	comment.Content = update.Content
+	c.JSON(http.StatusOK, comment)
+	return nil
+}
+
+// swagger:operation DELETE /comments/{commentid} comment deleteComment
+// Move a comment to the trash. Until it is restored or purged, a comment
+// with replies is listed as a tombstone so that the thread stays intact.
+// ---
+// produce:
+// - application/json
+// responses:
+//   '200':
+//     description: Success operation
+//   '400':
+//     description: Invalid comment ID
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: comment with provided ID not found
+func (handler *CommentsHandler) DeleteCommentHandler(c *gin.Context) error {
+	comment, err := handler.loadComment(c)
+	if err != nil {
+		return err
+	}
+	if err := authorize(c, comment.Username); err != nil {
+		return err
+	}
+
+	trash := models.Trash{DeletedBy: currentUsername(c), DeletedTime: time.Now()}
+	if err := handler.comments.TrashComment(c.Request.Context(), comment.CommentID, trash); err != nil {
+		return err
+	}
+	// the comment stays as a tombstone above replies outside the trash
+	comments, err := handler.comments.ListCommentsToPost(c.Request.Context(), comment.CommentToID)
+	if err != nil {
+		return err
+	}
+	comment.Trash = &trash
+	tombstone := len(hideTrashed([]models.Comment{comment}, comments)) > 0
+	c.JSON(http.StatusOK, gin.H{"deleteResult": "success", "tombstone": tombstone})
+	return nil
+}
+
+// hideTrashed drops the trashed comments without visible replies from
+// comments and turns those with visible replies into tombstones. replies
+// holds the comments that may reply to comments.
+func hideTrashed(comments, replies []models.Comment) []models.Comment {
+	byID := make(map[primitive.ObjectID]models.Comment, len(replies))
+	for _, reply := range replies {
+		byID[reply.CommentID] = reply
+	}
+	// parents are the comments above a reply that is not in the trash; a
+	// trashed reply counts only once a reply below it does
+	parents := make(map[primitive.ObjectID]bool)
+	for _, reply := range replies {
+		if reply.Trash != nil {
+			continue
+		}
+		for parent := reply.ParentID; parent != nil && !parents[*parent]; {
+			parents[*parent] = true
+			ancestor, ok := byID[*parent]
+			if !ok || ancestor.Trash == nil {
+				break
+			}
+			parent = ancestor.ParentID
+		}
+	}
+
+	shown := make([]models.Comment, 0, len(comments))
+	for _, comment := range comments {
+		if comment.Trash != nil {
+			if !parents[comment.CommentID] {
+				continue
+			}
+			comment.Deleted = true
+			comment.Username = ""
+			comment.Content = ""
+			comment.Trash = nil
+		}
+		shown = append(shown, comment)
+	}
+	return shown
+}
+
+// deleteComment removes a comment, or tombstones it when it has replies.
+// Removing a reply also removes tombstoned ancestors left without replies.
+func (handler *CommentsHandler) deleteComment(ctx context.Context, comment models.Comment) (bool, error) {
+	replies, err := handler.comments.CountReplies(ctx, comment.CommentID)
+	if err != nil {
+		return false, err
+	}
+	if replies > 0 {
+		return true, handler.comments.TombstoneComment(ctx, comment.CommentID)
+	}
 
-	if err != nil { // update error
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+	for {
+		if err := handler.comments.DeleteComment(ctx, comment.CommentID); err != nil {
+			return false, err
+		}
+		if comment.ParentID == nil {
+			return false, nil
+		}
+
+		parent, err := handler.comments.GetComment(ctx, *comment.ParentID)
+		if err == store.ErrNotFound {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !parent.Deleted {
+			return false, nil
+		}
+		replies, err := handler.comments.CountReplies(ctx, parent.CommentID)
+		if err != nil || replies > 0 {
+			return false, err
+		}
+		comment = parent
+	}
+}
+
+// commentPointers returns pointers to the elements of comments.
+func commentPointers(comments []models.Comment) []*models.Comment {
+	pointers := make([]*models.Comment, 0, len(comments))
+	for i := range comments {
+		pointers = append(pointers, &comments[i])
+	}
+	return pointers
+}
+
+// loadComment looks up the comment named by the commentid path parameter.
+// Tombstones, trashed comments and comments to posts the signed in user may
+// not see are not found.
+func (handler *CommentsHandler) loadComment(c *gin.Context) (models.Comment, error) {
+	commentID, err := paramID(c, "commentid")
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := handler.comments.GetComment(c.Request.Context(), commentID)
+	if err != nil {
+		return comment, err
+	}
+	if comment.Deleted || comment.Trash != nil {
+		return comment, store.ErrNotFound
 	}
-	c.JSON(http.StatusOK, gin.H{"thumbupResult": "success"})
+	return comment, handler.checkPost(c, comment.CommentToID)
 }
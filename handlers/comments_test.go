@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"blogo/models"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestComments(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"username":"alice","postTitle":"Hello"}`), "postID")
+
+	commentID := field(t, alice.mustDo("POST", "/comments/"+postID, `{"username":"alice","commentContent":"Nice"}`), "commentID")
+	alice.mustDo("POST", "/comments/thumbup/"+commentID, "")
+
+	var comments []models.Comment
+	decode(t, s.client(t).mustDo("GET", "/comments/"+postID, ""), &comments)
+	if len(comments) != 1 || comments[0].Content != "Nice" || comments[0].NumOfThumb != 1 {
+		t.Fatalf("GET /comments/%s: got %+v", postID, comments)
+	}
+}
+
+func TestCommentOwnership(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+
+	// the author comes from the session, not from the body
+	body := alice.mustDo("POST", "/comments/"+postID, `{"username":"bob","commentContent":"Nice"}`)
+	if got := field(t, body, "username"); got != "alice" {
+		t.Fatalf("comment author: got %q, want alice", got)
+	}
+	commentID := field(t, body, "commentID")
+
+	if code, _ := bob.do("PUT", "/comments/"+commentID, `{"commentContent":"Mine"}`); code != http.StatusForbidden {
+		t.Errorf("PUT by another user: got %d, want 403", code)
+	}
+	if code, _ := bob.do("DELETE", "/comments/"+commentID, ""); code != http.StatusForbidden {
+		t.Errorf("DELETE by another user: got %d, want 403", code)
+	}
+
+	if got := field(t, alice.mustDo("PUT", "/comments/"+commentID, `{"commentContent":"Edited"}`), "commentContent"); got != "Edited" {
+		t.Errorf("edited content: got %q", got)
+	}
+	alice.mustDo("DELETE", "/comments/"+commentID, "")
+	if code, _ := alice.do("PUT", "/comments/"+commentID, `{"commentContent":"Again"}`); code != http.StatusNotFound {
+		t.Errorf("PUT after delete: got %d, want 404", code)
+	}
+}
+
+// threads is the body of GET /comments/:postid?view=tree.
+type threads struct {
+	Threads []commentNode `json:"threads"`
+	Total   int64         `json:"total"`
+}
+
+func TestCommentThreads(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	otherID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Other"}`), "postID")
+
+	reply := func(parent, content string) string {
+		t.Helper()
+		body := `{"commentContent":"` + content + `"}`
+		if parent != "" {
+			body = `{"parentID":"` + parent + `","commentContent":"` + content + `"}`
+		}
+		return field(t, alice.mustDo("POST", "/comments/"+postID, body), "commentID")
+	}
+	first := reply("", "first")
+	answer := reply(first, "answer")
+	reply(answer, "answer to the answer")
+	reply("", "second")
+
+	var tree threads
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree", ""), &tree)
+	if tree.Total != 2 || len(tree.Threads) != 2 {
+		t.Fatalf("tree: got %+v", tree)
+	}
+	root := tree.Threads[0]
+	if root.Content != "first" || len(root.Replies) != 1 || len(root.Replies[0].Replies) != 1 {
+		t.Fatalf("first thread: got %+v", root)
+	}
+	nested := root.Replies[0].Replies[0]
+	if nested.Depth != 2 || nested.RootID == nil || nested.RootID.Hex() != first {
+		t.Errorf("nested reply: got depth %d and root %v", nested.Depth, nested.RootID)
+	}
+
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree&limit=1&offset=1", ""), &tree)
+	if tree.Total != 2 || len(tree.Threads) != 1 || tree.Threads[0].Content != "second" {
+		t.Errorf("second page of one thread: got %+v", tree)
+	}
+
+	if code, _ := alice.do("POST", "/comments/"+otherID, `{"parentID":"`+first+`","commentContent":"x"}`); code != http.StatusBadRequest {
+		t.Errorf("reply from another post: got %d, want 400", code)
+	}
+	if code, _ := alice.do("GET", "/comments/"+postID+"?view=graph", ""); code != http.StatusBadRequest {
+		t.Errorf("unknown view: got %d, want 400", code)
+	}
+}
+
+func TestCommentDepth(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+
+	parent := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"0"}`), "commentID")
+	for depth := 1; depth <= models.MaxCommentDepth; depth++ {
+		parent = field(t, alice.mustDo("POST", "/comments/"+postID, `{"parentID":"`+parent+`","commentContent":"deeper"}`), "commentID")
+	}
+	if code, _ := alice.do("POST", "/comments/"+postID, `{"parentID":"`+parent+`","commentContent":"too deep"}`); code != http.StatusBadRequest {
+		t.Errorf("reply beyond the maximum depth: got %d, want 400", code)
+	}
+}
+
+func TestCommentTombstones(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	first := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"first"}`), "commentID")
+	answer := field(t, alice.mustDo("POST", "/comments/"+postID, `{"parentID":"`+first+`","commentContent":"answer"}`), "commentID")
+
+	var result struct {
+		Tombstone bool `json:"tombstone"`
+	}
+	decode(t, alice.mustDo("DELETE", "/comments/"+first, ""), &result)
+	if !result.Tombstone {
+		t.Fatal("deleting a comment with replies leaves no tombstone")
+	}
+
+	var tree threads
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree", ""), &tree)
+	if len(tree.Threads) != 1 {
+		t.Fatalf("tree with a tombstone: got %+v", tree)
+	}
+	tombstone := tree.Threads[0]
+	if !tombstone.Deleted || tombstone.Content != "" || tombstone.Username != "" || len(tombstone.Replies) != 1 {
+		t.Errorf("tombstone: got %+v", tombstone)
+	}
+	for _, method := range []string{"PUT", "DELETE"} {
+		if code, _ := alice.do(method, "/comments/"+first, `{"commentContent":"back"}`); code != http.StatusNotFound {
+			t.Errorf("%s of a tombstone: got %d, want 404", method, code)
+		}
+	}
+	if code, _ := alice.do("POST", "/comments/"+postID, `{"parentID":"`+first+`","commentContent":"x"}`); code != http.StatusBadRequest {
+		t.Errorf("reply to a tombstone: got %d, want 400", code)
+	}
+
+	decode(t, alice.mustDo("DELETE", "/comments/"+answer, ""), &result)
+	if result.Tombstone {
+		t.Error("deleting a comment without replies leaves a tombstone")
+	}
+	// the last reply takes the tombstone with it
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree", ""), &tree)
+	if tree.Total != 0 || len(tree.Threads) != 0 {
+		t.Errorf("tree after deleting the last reply: got %+v", tree)
+	}
+}
+
+func TestCommentsOfHiddenPosts(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+
+	draft := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Draft","status":"draft"}`), "postID")
+	trashed := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Trashed"}`), "postID")
+	commentID := field(t, alice.mustDo("POST", "/comments/"+trashed, `{"commentContent":"Nice"}`), "commentID")
+	alice.mustDo("DELETE", "/posts/"+trashed, "")
+
+	for _, postID := range []string{draft, trashed, primitive.NewObjectID().Hex()} {
+		if code, _ := bob.do("GET", "/comments/"+postID, ""); code != http.StatusNotFound {
+			t.Errorf("GET /comments/%s: got %d, want 404", postID, code)
+		}
+		if code, _ := bob.do("POST", "/comments/"+postID, `{"commentContent":"Hi"}`); code != http.StatusNotFound {
+			t.Errorf("POST /comments/%s: got %d, want 404", postID, code)
+		}
+	}
+	if code, _ := alice.do("POST", "/comments/"+trashed, `{"commentContent":"Hi"}`); code != http.StatusNotFound {
+		t.Errorf("comment to an own trashed post: got %d, want 404", code)
+	}
+	// comments of a trashed post cannot be changed either
+	for _, request := range [][3]string{
+		{"PUT", "/comments/" + commentID, `{"commentContent":"Edited"}`},
+		{"PUT", "/comments/" + commentID + "/reactions", `{"type":"like"}`},
+		{"DELETE", "/comments/" + commentID + "/reactions", ""},
+		{"DELETE", "/comments/" + commentID, ""},
+	} {
+		if code, _ := alice.do(request[0], request[1], request[2]); code != http.StatusNotFound {
+			t.Errorf("%s %s: got %d, want 404", request[0], request[1], code)
+		}
+	}
+	// the author still sees the comments of their own draft
+	if code, body := alice.do("GET", "/comments/"+draft, ""); code != http.StatusOK {
+		t.Errorf("comments of an own draft: got %d %s, want 200", code, body)
+	}
+}
+
+func TestHideTrashed(t *testing.T) {
+	comment := func(parent *models.Comment, trashed bool) models.Comment {
+		c := models.Comment{CommentID: primitive.NewObjectID(), Username: "alice", Content: "Hi"}
+		if parent != nil {
+			c.ParentID = &parent.CommentID
+		}
+		if trashed {
+			c.Trash = &models.Trash{DeletedBy: "alice"}
+		}
+		return c
+	}
+	// root <- trashed reply <- visible reply, and lonely <- trashed reply
+	root := comment(nil, true)
+	middle := comment(&root, true)
+	leaf := comment(&middle, false)
+	lonely := comment(nil, true)
+	hidden := comment(&lonely, true)
+	all := []models.Comment{root, middle, leaf, lonely, hidden}
+
+	shown := hideTrashed(all, all)
+	if len(shown) != 3 {
+		t.Fatalf("got %d comments, want the chain down to the visible reply", len(shown))
+	}
+	for i, want := range []models.Comment{root, middle, leaf} {
+		got := shown[i]
+		if got.CommentID != want.CommentID || got.Trash != nil {
+			t.Errorf("comment %d: got %+v", i, got)
+		}
+		if tombstone := want.Trash != nil; got.Deleted != tombstone || (tombstone && got.Content != "") {
+			t.Errorf("comment %d: deleted %v with %q, want tombstone %v", i, got.Deleted, got.Content, tombstone)
+		}
+	}
+}
+
+func TestCommentThreadsSkipHiddenThreads(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	alone := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"alone"}`), "commentID")
+	asked := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"asked"}`), "commentID")
+	answer := field(t, alice.mustDo("POST", "/comments/"+postID, `{"parentID":"`+asked+`","commentContent":"answer"}`), "commentID")
+	kept := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"kept"}`), "commentID")
+
+	alice.mustDo("DELETE", "/comments/"+alone, "")
+	alice.mustDo("DELETE", "/comments/"+answer, "")
+	var result struct {
+		Tombstone bool `json:"tombstone"`
+	}
+	decode(t, alice.mustDo("DELETE", "/comments/"+asked, ""), &result)
+	if result.Tombstone {
+		t.Error("deleting a comment with only trashed replies leaves a tombstone")
+	}
+
+	// hidden threads count neither towards the total nor the offset
+	var tree threads
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree&limit=1", ""), &tree)
+	if tree.Total != 1 || len(tree.Threads) != 1 || tree.Threads[0].CommentID.Hex() != kept {
+		t.Errorf("first page: got %+v", tree)
+	}
+	decode(t, alice.mustDo("GET", "/comments/"+postID+"?view=tree&limit=1&offset=1", ""), &tree)
+	if len(tree.Threads) != 0 {
+		t.Errorf("second page: got %+v", tree)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/store"
+	"blogo/tokens"
+	"blogo/validation"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrorMiddleware renders the errors returned by the handlers.
+func ErrorMiddleware() gin.HandlerFunc {
+	return apierror.Middleware(classifyError)
+}
+
+// classifyError maps the errors of the stores and the token manager to API
+// errors.
+func classifyError(err error) *apierror.Error {
+	switch err {
+	case store.ErrNotFound:
+		return apierror.Wrap(apierror.CodeNotFound, err)
+	case store.ErrDuplicate:
+		return apierror.Wrap(apierror.CodeConflict, err)
+	case store.ErrInvalidCursor:
+		return apierror.Wrap(apierror.CodeBadRequest, err)
+	case tokens.ErrInvalid, tokens.ErrRevoked:
+		return apierror.Wrap(apierror.CodeUnauthenticated, err)
+	}
+	return nil
+}
+
+// bindJSON decodes the request body into v and checks it against the
+// binding rules of v. Bodies that do not decode or break the rules are bad
+// requests.
+func bindJSON(c *gin.Context, v interface{}) error {
+	if err := c.ShouldBindJSON(v); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+// validate checks v, a value assembled from a request, against its binding
+// rules.
+func validate(v interface{}) error {
+	if err := validation.Struct(v); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+func invalid(err error) error {
+	if fields, ok := validation.Fields(err); ok {
+		return apierror.Invalid(fields)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.New(apierror.CodeTooLarge, "the request body exceeds %d bytes", tooLarge.Limit)
+	}
+	return apierror.Wrap(apierror.CodeBadRequest, err)
+}
+
+// LimitBody rejects request bodies larger than limit bytes.
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, apierror.New(apierror.CodeTooLarge, "the request body exceeds %d bytes", limit))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// paramID reads the ObjectID in the path parameter name.
+func paramID(c *gin.Context, name string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Param(name))
+	if err != nil {
+		return id, apierror.New(apierror.CodeBadRequest, "%s is not a valid id", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		fields []apierror.FieldError
+	}{
+		{"sign up", "POST", "/signup", `{"username":"a b","password":"short"}`, []apierror.FieldError{
+			{Field: "username", Message: "may only contain letters, digits, '.', '_' and '-'"},
+			{Field: "password", Message: "must have at least 8 characters"},
+		}},
+		{"password without digit", "POST", "/signup", `{"username":"bob","password":"password"}`, []apierror.FieldError{
+			{Field: "password", Message: "must contain a letter and a digit"},
+		}},
+		{"post", "POST", "/posts", `{"postTags":["go",""]}`, []apierror.FieldError{
+			{Field: "postTitle", Message: "is required"},
+			{Field: "postTags[1]", Message: "is required"},
+		}},
+		{"too many tags", "POST", "/posts", `{"postTitle":"Tags","postTags":["1","2","3","4","5","6","7","8","9","10","11"]}`, []apierror.FieldError{
+			{Field: "postTags", Message: "must have at most 10 items"},
+		}},
+	}
+	for _, tt := range tests {
+		code, body := alice.do(tt.method, tt.path, tt.body)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", tt.name, code)
+			continue
+		}
+		var problem apierror.Problem
+		decode(t, body, &problem)
+		if !reflect.DeepEqual(problem.Errors, tt.fields) {
+			t.Errorf("%s: got field errors %+v, want %+v", tt.name, problem.Errors, tt.fields)
+		}
+	}
+
+	// accounts from before the rules may still sign in
+	if code, _ := s.client(t).do("POST", "/signin", `{"username":"al","password":"x"}`); code != http.StatusUnauthorized {
+		t.Errorf("sign in with a short password: got %d, want 401", code)
+	}
+
+	huge := `{"postTitle":"Huge","postContent":"` + strings.Repeat("a", 1<<16) + `"}`
+	if code, _ := alice.do("POST", "/posts", huge); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the limit: got %d, want 413", code)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"blogo/feed"
+	"blogo/models"
+	"blogo/store"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedHandler serves the newest published posts as feeds, for the whole
+// blog, for a tag and for an author.
+type FeedHandler struct {
+	posts *PostsHandler
+	title string
+	url   string
+	size  int
+	// started stands in for the time of the newest change of a feed
+	// without posts.
+	started time.Time
+}
+
+// NewFeedHandler creates the feed handlers. Links in the feeds start with
+// publicURL, the address the API is reached at, and every feed holds the
+// size newest posts.
+func NewFeedHandler(posts *PostsHandler, title, publicURL string, size int) *FeedHandler {
+	return &FeedHandler{
+		posts:   posts,
+		title:   title,
+		url:     strings.TrimSuffix(publicURL, "/"),
+		size:    size,
+		started: time.Now(),
+	}
+}
+
+// swagger:operation GET /feed.rss feed rssFeed
+// Subscribe to the newest posts as RSS 2.0. The posts of a tag are at
+// /tags/{slug}/feed.rss and those of an author at
+// /authors/{username}/feed.rss.
+// ---
+// produces:
+// - application/rss+xml
+// responses:
+//   '200':
+//     description: Successful operation
+//   '301':
+//     description: slug is an alias, follow the redirect to the tag
+//   '304':
+//     description: Feed unchanged since the ETag or Last-Modified time of the request
+func (handler *FeedHandler) RSSHandler(c *gin.Context) error {
+	return handler.serve(c, feed.RSS)
+}
+
+// swagger:operation GET /feed.atom feed atomFeed
+// Subscribe to the newest posts as Atom. The posts of a tag are at
+// /tags/{slug}/feed.atom and those of an author at
+// /authors/{username}/feed.atom.
+// ---
+// produces:
+// - application/atom+xml
+// responses:
+//   '200':
+//     description: Successful operation
+//   '301':
+//     description: slug is an alias, follow the redirect to the tag
+//   '304':
+//     description: Feed unchanged since the ETag or Last-Modified time of the request
+func (handler *FeedHandler) AtomHandler(c *gin.Context) error {
+	return handler.serve(c, feed.Atom)
+}
+
+// swagger:operation GET /feed.json feed jsonFeed
+// Subscribe to the newest posts as JSON Feed. The posts of a tag are at
+// /tags/{slug}/feed.json and those of an author at
+// /authors/{username}/feed.json.
+// ---
+// produces:
+// - application/feed+json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '301':
+//     description: slug is an alias, follow the redirect to the tag
+//   '304':
+//     description: Feed unchanged since the ETag or Last-Modified time of the request
+func (handler *FeedHandler) JSONHandler(c *gin.Context) error {
+	return handler.serve(c, feed.JSON)
+}
+
+// serve writes the feed selected by the path parameters in format. The
+// ETag and Last-Modified headers are derived from the posts of the feed,
+// so that readers polling an unchanged feed get an empty 304 response.
+func (handler *FeedHandler) serve(c *gin.Context, format feed.Format) error {
+	query := store.PostQuery{
+		Limit:  handler.size,
+		Sort:   store.SortCreated,
+		Status: models.StatusPublished,
+		Author: c.Param("username"),
+	}
+	doc := feed.Feed{
+		Title:       handler.title,
+		Description: "The newest posts of " + handler.title,
+		Link:        handler.url + "/posts",
+		URL:         handler.url + c.Request.URL.Path,
+	}
+	if c.Param("slug") != "" {
+		tag, ok, err := handler.posts.canonicalTag(c, "feed."+string(format))
+		if err != nil || !ok {
+			return err
+		}
+		names, err := handler.posts.tagNames(c.Request.Context())
+		if err != nil {
+			return err
+		}
+		query.Tag = tag
+		doc.Title = handler.title + ": " + nameOr(names, tag)
+		doc.Description = "The newest posts tagged " + nameOr(names, tag)
+		doc.Link = handler.url + "/tags/" + tag + "/posts"
+	}
+	if query.Author != "" {
+		doc.Title = handler.title + ": " + query.Author
+		doc.Description = "The newest posts by " + query.Author
+		doc.Link = handler.url + "/posts?author=" + url.QueryEscape(query.Author)
+	}
+
+	page, err := handler.posts.postsPage(c, query)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", format, doc.Title, doc.URL)
+	for _, post := range page.Posts {
+		if post.LastUpdatedTime.After(doc.Updated) {
+			doc.Updated = post.LastUpdatedTime
+		}
+		fmt.Fprintf(hash, "%s %d\n", post.PostID.Hex(), post.LastUpdatedTime.UnixNano())
+	}
+	if doc.Updated.IsZero() {
+		doc.Updated = handler.started
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	c.Header("ETag", etag)
+	if !doc.Updated.IsZero() {
+		c.Header("Last-Modified", doc.Updated.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request, etag, doc.Updated) {
+		c.Status(http.StatusNotModified)
+		return nil
+	}
+
+	for _, post := range page.Posts {
+		html, err := handler.posts.renderContent(post)
+		if err != nil {
+			return err
+		}
+		id := handler.url + "/posts/" + post.PostID.Hex()
+		link := id
+		if post.Slug != "" {
+			link = handler.url + "/p/" + url.PathEscape(post.Slug)
+		}
+		doc.Entries = append(doc.Entries, feed.Entry{
+			ID:        id,
+			Link:      link,
+			Title:     post.Title,
+			Author:    post.Username,
+			Tags:      post.Tags,
+			HTML:      html,
+			Published: post.CreatedTime,
+			Updated:   post.LastUpdatedTime,
+		})
+	}
+
+	body, err := format.Render(doc)
+	if err != nil {
+		return err
+	}
+	c.Data(http.StatusOK, format.ContentType(), body)
+	return nil
+}
+
+// notModified reports whether the conditional headers of r show that the
+// client already has the response identified by etag and lastModified.
+// If-None-Match takes precedence over If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.IsZero() && !lastModified.Truncate(time.Second).After(since)
+}
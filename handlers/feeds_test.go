@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getFeed requests a feed with the given request headers.
+func getFeed(s *testServer, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// feedTitles returns the titles of the items of a JSON feed.
+func feedTitles(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	var doc struct {
+		Title string `json:"title"`
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	titles := make([]string, 0, len(doc.Items))
+	for _, item := range doc.Items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestFeeds(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	alice.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["go"]}`)
+	bob.mustDo("POST", "/posts", `{"postTitle":"Two","postTags":["go"]}`)
+	alice.mustDo("POST", "/posts", `{"postTitle":"Three","postTags":["web"]}`)
+	alice.mustDo("POST", "/posts", `{"postTitle":"Draft","postTags":["go"],"status":"draft"}`)
+
+	// the test server keeps feeds at two posts
+	tests := []struct {
+		path   string
+		titles string
+	}{
+		{"/feed.json", "Three Two"},
+		{"/tags/go/feed.json", "Two One"},
+		{"/authors/alice/feed.json", "Three One"},
+		{"/authors/carol/feed.json", ""},
+	}
+	for _, tt := range tests {
+		if titles := strings.Join(feedTitles(t, getFeed(s, tt.path, nil)), " "); titles != tt.titles {
+			t.Errorf("%s: got %q, want %q", tt.path, titles, tt.titles)
+		}
+	}
+
+	for path, contentType := range map[string]string{
+		"/feed.rss":  "application/rss+xml; charset=utf-8",
+		"/feed.atom": "application/atom+xml; charset=utf-8",
+		"/feed.json": "application/feed+json; charset=utf-8",
+	} {
+		w := getFeed(s, path, nil)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType {
+			t.Errorf("%s: got %d %q", path, w.Code, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), "http://blogo.test/posts/") {
+			t.Errorf("%s: links do not start with the public URL:\n%s", path, w.Body.String())
+		}
+	}
+}
+
+func TestEmptyAtomFeed(t *testing.T) {
+	s := newTestServer(t)
+	w := getFeed(s, "/authors/carol/feed.atom", nil)
+	var doc struct {
+		Updated time.Time `xml:"updated"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Updated.Before(time.Now().Add(-time.Hour)) {
+		t.Errorf("updated of a feed without posts: got %v", doc.Updated)
+	}
+}
+
+func TestFeedConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"One"}`), "postID")
+
+	w := getFeed(s, "/feed.atom", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("got ETag %q and Last-Modified %q", etag, lastModified)
+	}
+	if other := getFeed(s, "/feed.rss", nil).Header().Get("ETag"); other == etag {
+		t.Error("RSS and Atom feeds share an ETag")
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		code   int
+	}{
+		{"matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak ETag in a list", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"other ETag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		// If-None-Match wins over If-Modified-Since
+		{"other ETag, not modified since", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := getFeed(s, "/feed.atom", tt.header)
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: 304 with a body", tt.name)
+		}
+	}
+
+	alice.mustDo("PATCH", "/posts/"+id, `{"postTitle":"One, edited"}`)
+	w = getFeed(s, "/feed.atom", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("feed after an edit: got %d with ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/models"
+	"blogo/search"
+	"blogo/store"
+	"blogo/tokens"
+	"blogo/validation"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testServer is the router of main.go on in-memory stores, along with the
+// stores and handlers so that tests can look behind the API.
+type testServer struct {
+	router    *gin.Engine
+	posts     *store.MemoryPostStore
+	revisions *store.MemoryRevisionStore
+	tags      *store.MemoryTagStore
+	reactions *store.MemoryReactionStore
+	search    *search.InvertedIndex
+	comments  *store.MemoryCommentStore
+	users     *store.MemoryUserStore
+
+	postsHandler    *PostsHandler
+	commentsHandler *CommentsHandler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newCustomTestServer(t, AuthModeSession, nil)
+}
+
+// newCustomTestServer is newTestServer for the given authentication mode
+// and Redis client, which may be nil. Tokens are signed with a fixed secret
+// and revoked in memory.
+func newCustomTestServer(t *testing.T, mode AuthMode, redisClient *redis.Client) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		posts:     store.NewMemoryPostStore(),
+		revisions: store.NewMemoryRevisionStore(),
+		tags:      store.NewMemoryTagStore(),
+		reactions: store.NewMemoryReactionStore(),
+		search:    search.NewInvertedIndex(),
+		comments:  store.NewMemoryCommentStore(),
+		users:     store.NewMemoryUserStore(),
+	}
+	s.postsHandler = NewPostsHandlers(s.posts, s.revisions, s.tags, s.reactions, s.search, redisClient)
+	s.commentsHandler = NewCommentsHandlers(s.comments, s.posts, s.reactions, redisClient)
+	postsHandler, commentsHandler := s.postsHandler, s.commentsHandler
+	var tokenManager *tokens.Manager
+	if mode.UsesTokens() {
+		tokenManager = tokens.NewManager([]byte("secret"), time.Minute, time.Hour, tokens.NewMemoryDenylist())
+	}
+	authHandler := NewAuthHandler(s.users, mode, tokenManager)
+	adminHandler := NewAdminHandler(s.users)
+	trashHandler := NewTrashHandler(postsHandler, commentsHandler, time.Hour)
+	feedHandler := NewFeedHandler(postsHandler, "Blogo", "http://blogo.test/", 2)
+
+	handle := apierror.Handler
+	router := gin.New()
+	router.Use(sessions.Sessions("post_api", cookie.NewStore([]byte("secret"))))
+	router.Use(ErrorMiddleware())
+	router.Use(LimitBody(1 << 16))
+	router.POST("/signin", handle(authHandler.SignInHandler))
+	router.POST("/singout", handle(authHandler.SignOutHandler))
+	router.POST("/signup", handle(authHandler.SignUpHandler))
+	if mode.UsesTokens() {
+		router.POST("/token/refresh", handle(authHandler.RefreshTokenHandler))
+	}
+
+	viewer := router.Group("/")
+	viewer.Use(authHandler.OptionalAuthMiddleware())
+	{
+		viewer.GET("/posts", handle(postsHandler.ListPostsHandler))
+		viewer.GET("/posts/:id", handle(postsHandler.ViewPostHandler))
+		viewer.GET("/posts/search/:title", handle(postsHandler.SearchPostHandler))
+		viewer.GET("/random-post", handle(postsHandler.GetOneRandomPost))
+		viewer.GET("/search", handle(postsHandler.SearchHandler))
+		viewer.GET("/tags/:slug/posts", handle(postsHandler.ListTagPostsHandler))
+		viewer.GET("/posts/:id/reactions", handle(postsHandler.ListPostReactionsHandler))
+		viewer.GET("/p/:slug", handle(postsHandler.ViewPostBySlugHandler))
+		viewer.GET("/comments/:postid", handle(commentsHandler.ListCommentsToPostHandler))
+	}
+
+	router.GET("/tags", handle(postsHandler.ListTagsHandler))
+	router.GET("/tags/:slug/related", handle(postsHandler.RelatedTagsHandler))
+
+	router.GET("/feed.rss", handle(feedHandler.RSSHandler))
+	router.GET("/feed.atom", handle(feedHandler.AtomHandler))
+	router.GET("/feed.json", handle(feedHandler.JSONHandler))
+	router.GET("/tags/:slug/feed.rss", handle(feedHandler.RSSHandler))
+	router.GET("/tags/:slug/feed.atom", handle(feedHandler.AtomHandler))
+	router.GET("/tags/:slug/feed.json", handle(feedHandler.JSONHandler))
+	router.GET("/authors/:username/feed.rss", handle(feedHandler.RSSHandler))
+	router.GET("/authors/:username/feed.atom", handle(feedHandler.AtomHandler))
+	router.GET("/authors/:username/feed.json", handle(feedHandler.JSONHandler))
+
+	authorized := router.Group("/")
+	authorized.Use(authHandler.AuthMiddileware())
+	{
+		authorized.DELETE("/posts/:id", handle(postsHandler.DeletePostHandler))
+		authorized.PUT("/posts/:id", handle(postsHandler.UpdatePostHandler))
+		authorized.PATCH("/posts/:id", handle(postsHandler.PatchPostHandler))
+		authorized.GET("/posts/:id/revisions", handle(postsHandler.ListRevisionsHandler))
+		authorized.GET("/posts/:id/revisions/:revision", handle(postsHandler.ViewRevisionHandler))
+		authorized.GET("/posts/:id/diff", handle(postsHandler.DiffRevisionsHandler))
+		authorized.POST("/posts/:id/revisions/:revision/restore", handle(postsHandler.RestoreRevisionHandler))
+		authorized.POST("/posts/thumbup/:id", handle(postsHandler.ThumbupPostHandler))
+		authorized.PUT("/posts/:id/reactions", handle(postsHandler.ReactPostHandler))
+		authorized.DELETE("/posts/:id/reactions", handle(postsHandler.UnreactPostHandler))
+		authorized.POST("/comments/:postid", handle(commentsHandler.CreateCommentToPostHandler))
+		authorized.POST("/comments/thumbup/:commentid", handle(commentsHandler.CommentThumbupHandler))
+		authorized.PUT("/comments/:commentid", handle(commentsHandler.UpdateCommentHandler))
+		authorized.DELETE("/comments/:commentid", handle(commentsHandler.DeleteCommentHandler))
+		authorized.POST("/posts/:id/restore", handle(trashHandler.RestorePostHandler))
+		authorized.POST("/comments/restore/:commentid", handle(trashHandler.RestoreCommentHandler))
+		authorized.GET("/trash", handle(trashHandler.ListTrashHandler))
+		authorized.PUT("/comments/:commentid/reactions", handle(commentsHandler.ReactCommentHandler))
+		authorized.DELETE("/comments/:commentid/reactions", handle(commentsHandler.UnreactCommentHandler))
+	}
+
+	writers := authorized.Group("/")
+	writers.Use(RequireRole(models.RoleAuthor, models.RoleModerator, models.RoleAdmin))
+	{
+		writers.POST("/posts", handle(postsHandler.NewPostHandler))
+	}
+
+	admin := authorized.Group("/admin")
+	admin.Use(RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handle(adminHandler.ListUsersHandler))
+		admin.PUT("/users/:username/role", handle(adminHandler.UpdateUserRoleHandler))
+		admin.POST("/users/:username/disable", handle(adminHandler.DisableUserHandler))
+		admin.POST("/users/:username/enable", handle(adminHandler.EnableUserHandler))
+		admin.POST("/tags/:slug/rename", handle(postsHandler.RenameTagHandler))
+		admin.POST("/tags/:slug/merge", handle(postsHandler.MergeTagsHandler))
+	}
+	s.router = router
+	return s
+}
+
+// newTestRedis starts an in-process Redis server for one test.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// setRole changes the role of a user behind the API.
+func (s *testServer) setRole(t *testing.T, username string, role models.Role) {
+	t.Helper()
+	if err := s.users.UpdateUserRole(context.Background(), username, role); err != nil {
+		t.Fatalf("set role of %s: %v", username, err)
+	}
+}
+
+// client sends requests to a test server and keeps the session cookie, like
+// a browser would. It also sends bearer as access token when it is set.
+func (s *testServer) client(t *testing.T) *testClient {
+	return &testClient{t: t, server: s, router: s.router}
+}
+
+type testClient struct {
+	t       *testing.T
+	server  *testServer
+	router  http.Handler
+	cookies []*http.Cookie
+	bearer  string
+}
+
+func (client *testClient) do(method, path, body string) (int, []byte) {
+	client.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+client.bearer)
+	}
+	for _, c := range client.cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	client.router.ServeHTTP(w, req)
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		client.cookies = cookies
+	}
+	return w.Code, w.Body.Bytes()
+}
+
+// mustDo is do for requests that have to succeed with status 200.
+func (client *testClient) mustDo(method, path, body string) []byte {
+	client.t.Helper()
+	code, response := client.do(method, path, body)
+	if code != http.StatusOK {
+		client.t.Fatalf("%s %s: got %d %s", method, path, code, response)
+	}
+	return response
+}
+
+// signUp creates an author account and signs the client in with it.
+func (client *testClient) signUp(username string) {
+	client.t.Helper()
+	client.mustDo("POST", "/signup", `{"username":"`+username+`","password":"passw0rd!"}`)
+	client.server.setRole(client.t, username, models.RoleAuthor)
+}
+
+// decode unmarshals a JSON response into v.
+func decode(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+}
+
+// field returns a top level string field of a JSON object response.
+func field(t *testing.T, body []byte, name string) string {
+	t.Helper()
+	var object map[string]interface{}
+	decode(t, body, &object)
+	value, _ := object[name].(string)
+	return value
+}
+
+// mustObjectID parses an ID taken from a response.
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck reaches one dependency of the API.
+type HealthCheck struct {
+	Name string
+	// Timeout bounds Ping. Pings that ignore their context are abandoned
+	// once it passes.
+	Timeout time.Duration
+	Ping    func(ctx context.Context) error
+}
+
+// checkResult is the report of one HealthCheck.
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type HealthHandler struct {
+	checks []HealthCheck
+}
+
+func NewHealthHandler(checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// swagger:operation GET /livez health liveness
+// Report that the process is up
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+func (handler *HealthHandler) LivenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// swagger:operation GET /healthz health health
+// Same as /readyz, for load balancers that look for /healthz
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: All dependencies are reachable
+//   '503':
+//     description: Some dependency is unreachable
+func (handler *HealthHandler) HealthzHandler(c *gin.Context) {
+	handler.ReadinessHandler(c)
+}
+
+// swagger:operation GET /readyz health readiness
+// Ping every dependency and report their status and latency
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: All dependencies are reachable
+//   '503':
+//     description: Some dependency is unreachable
+func (handler *HealthHandler) ReadinessHandler(c *gin.Context) {
+	results := handler.run(c.Request.Context())
+
+	status, code := statusOK, http.StatusOK
+	for _, result := range results {
+		if result.Status != statusOK {
+			status, code = statusUnavailable, http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// run pings all dependencies at once, each under its own timeout.
+func (handler *HealthHandler) run(ctx context.Context) map[string]checkResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]checkResult, len(handler.checks))
+	)
+	for _, check := range handler.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			result := ping(ctx, check)
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return results
+}
+
+func ping(ctx context.Context, check HealthCheck) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Ping(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := checkResult{
+		Status:    statusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = statusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
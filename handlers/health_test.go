@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := HealthCheck{Name: "mongo", Timeout: time.Second, Ping: func(ctx context.Context) error {
+		return nil
+	}}
+	down := HealthCheck{Name: "redis", Timeout: time.Second, Ping: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}}
+	// a ping that ignores its context is abandoned after the timeout
+	stuck := HealthCheck{Name: "sessionRedis", Timeout: 10 * time.Millisecond, Ping: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	tests := []struct {
+		name   string
+		checks []HealthCheck
+		code   int
+		failed []string
+	}{
+		{"all up", []HealthCheck{ok}, http.StatusOK, nil},
+		{"one down", []HealthCheck{ok, down}, http.StatusServiceUnavailable, []string{"redis"}},
+		{"timeout", []HealthCheck{ok, stuck}, http.StatusServiceUnavailable, []string{"sessionRedis"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/readyz", NewHealthHandler(tt.checks...).ReadinessHandler)
+			start := time.Now()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("readiness took %v", time.Since(start))
+			}
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+
+			var body struct {
+				Status string                 `json:"status"`
+				Checks map[string]checkResult `json:"checks"`
+			}
+			decode(t, w.Body.Bytes(), &body)
+			if len(body.Checks) != len(tt.checks) {
+				t.Fatalf("got checks %+v", body.Checks)
+			}
+			var failed []string
+			for name, result := range body.Checks {
+				if result.Status != statusOK {
+					failed = append(failed, name)
+					if result.Error == "" {
+						t.Errorf("%s: no error reported", name)
+					}
+				}
+			}
+			if len(failed) != len(tt.failed) || (len(failed) > 0 && failed[0] != tt.failed[0]) {
+				t.Errorf("failed checks: got %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
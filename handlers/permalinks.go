@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/slug"
+	"blogo/store"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/context"
+)
+
+const (
+	// maxSlugLength bounds slugs in runes, before a number is appended.
+	maxSlugLength = 80
+	// maxSlugAttempts is how many numbered slugs are tried before the post
+	// ID is appended instead.
+	maxSlugAttempts = 20
+)
+
+// swagger:operation GET /p/{slug} post viewPostBySlug
+// View a post given its slug
+// ---
+// produces:
+// - application/json
+// description: Takes the format parameter of GET /posts/{id}.
+// parameters:
+//   - name: slug
+//     in: path
+//     description: slug of the post
+//     required: true
+//     type: string
+// responses:
+//  '200':
+//   description: Successful operation
+//  '301':
+//   description: slug is a former slug of the post, follow the redirect to its permalink
+//  '400':
+//   description: Invalid format
+//  '404':
+//   description: post with provided slug not found, or a draft of another user
+func (handler *PostsHandler) ViewPostBySlugHandler(c *gin.Context) error {
+	param := c.Param("slug")
+	post, err := handler.posts.GetPostBySlug(c.Request.Context(), param)
+	if err != nil {
+		return err
+	}
+	if !canView(c, post) {
+		return store.ErrNotFound
+	}
+
+	if post.Slug != param {
+		location := "/p/" + url.PathEscape(post.Slug)
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, location)
+		return nil
+	}
+	return handler.viewPost(c, post)
+}
+
+// saveWithSlug derives a slug for post with uniqueSlug and hands it to
+// save. When another post took the slug in the meantime, so that save fails
+// with store.ErrDuplicate, the next free slug is tried. Once saved, the slug
+// no longer redirects from posts that had it before.
+func (handler *PostsHandler) saveWithSlug(ctx context.Context, post models.Post, save func(slug string) error) error {
+	for n := 1; ; n++ {
+		candidate, attempt, err := handler.uniqueSlug(ctx, post, n)
+		if err != nil {
+			return err
+		}
+		err = save(candidate)
+		if err == nil {
+			// a post that had the slug before gives up its redirect
+			return handler.posts.ReleaseSlug(ctx, candidate, post.PostID)
+		}
+		if err != store.ErrDuplicate || attempt > maxSlugAttempts {
+			return err
+		}
+		n = attempt
+	}
+}
+
+// uniqueSlug derives the slug of post from its title. When another post has
+// or had that slug, a number is appended. A slug the post had before may be
+// taken back. Numbers below from are skipped; the returned attempt is the
+// number the slug was made with.
+func (handler *PostsHandler) uniqueSlug(ctx context.Context, post models.Post, from int) (string, int, error) {
+	base := slug.Limit(slug.Make(post.Title), maxSlugLength)
+	if base == "" {
+		base = "post"
+	}
+
+	for n := from; n <= maxSlugAttempts; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		owner, err := handler.posts.GetPostBySlug(ctx, candidate)
+		if err == store.ErrNotFound || (err == nil && owner.PostID == post.PostID) {
+			return candidate, n, nil
+		}
+		if err != nil {
+			return "", n, err
+		}
+	}
+	// the ID is unique without looking it up
+	return base + "-" + post.PostID.Hex(), maxSlugAttempts + 1, nil
+}
+
+// withoutSlug returns slugs without s.
+func withoutSlug(slugs []string, s string) []string {
+	kept := make([]string, 0, len(slugs))
+	for _, old := range slugs {
+		if old != s {
+			kept = append(kept, old)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/store"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPermalinks(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+
+	var first, second, empty models.Post
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello, World!"}`), &first)
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello world"}`), &second)
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"!!!"}`), &empty)
+	if first.Slug != "hello-world" || second.Slug != "hello-world-2" || empty.Slug != "post" {
+		t.Fatalf("slugs: got %q, %q and %q", first.Slug, second.Slug, empty.Slug)
+	}
+
+	var post models.Post
+	decode(t, s.client(t).mustDo("GET", "/p/hello-world-2", ""), &post)
+	if post.PostID != second.PostID {
+		t.Errorf("GET /p/hello-world-2: got post %s, want %s", post.PostID.Hex(), second.PostID.Hex())
+	}
+	if code, _ := s.client(t).do("GET", "/p/nothing-here", ""); code != http.StatusNotFound {
+		t.Errorf("unknown slug: got %d, want 404", code)
+	}
+
+	// the old slug redirects to the new one
+	decode(t, alice.mustDo("PATCH", "/posts/"+first.PostID.Hex(), `{"postTitle":"Goodbye"}`), &post)
+	if post.Slug != "goodbye" {
+		t.Fatalf("slug after a new title: got %q", post.Slug)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/p/hello-world?format=html", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/p/goodbye?format=html" {
+		t.Errorf("GET of an old slug: got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// the post may take its old slug back
+	decode(t, alice.mustDo("PATCH", "/posts/"+first.PostID.Hex(), `{"postTitle":"Hello World"}`), &post)
+	if post.Slug != "hello-world" {
+		t.Errorf("slug after the old title: got %q", post.Slug)
+	}
+
+	alice.mustDo("POST", "/posts", `{"postTitle":"Secret","status":"draft"}`)
+	alice.mustDo("GET", "/p/secret", "")
+	if code, _ := s.client(t).do("GET", "/p/secret", ""); code != http.StatusNotFound {
+		t.Errorf("draft by slug seen by another user: got %d, want 404", code)
+	}
+}
+
+func TestSaveWithSlugRetries(t *testing.T) {
+	s := newTestServer(t)
+	post := models.Post{PostID: primitive.NewObjectID(), Title: "Hello"}
+
+	// a concurrent post takes each slug right after it was looked up
+	var tried []string
+	err := s.postsHandler.saveWithSlug(context.Background(), post, func(slug string) error {
+		tried = append(tried, slug)
+		if len(tried) < 3 {
+			return store.ErrDuplicate
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hello", "hello-2", "hello-3"}; !reflect.DeepEqual(tried, want) {
+		t.Errorf("tried %v, want %v", tried, want)
+	}
+
+	failure := errors.New("database down")
+	err = s.postsHandler.saveWithSlug(context.Background(), post, func(string) error { return failure })
+	if err != failure {
+		t.Errorf("got %v, want the error of save", err)
+	}
+}
+
+func TestSlugFollowsOnlyTheTitle(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	ctx := context.Background()
+
+	var first, second models.Post
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), &first)
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), &second)
+	alice.mustDo("DELETE", "/posts/"+first.PostID.Hex(), "")
+	if err := NewTrashHandler(s.postsHandler, s.commentsHandler, 0).Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// hello is free now, but links to hello-2 keep working
+	var post models.Post
+	decode(t, alice.mustDo("PATCH", "/posts/"+second.PostID.Hex(), `{"postContent":"edited"}`), &post)
+	if post.Slug != "hello-2" || len(post.OldSlugs) != 0 {
+		t.Errorf("slug after editing the content: got %q and %v", post.Slug, post.OldSlugs)
+	}
+}
+
+func TestClaimedSlugsAreReleased(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	ctx := context.Background()
+	post := models.Post{PostID: primitive.NewObjectID(), Username: "alice", Title: "Hello", CreatedTime: time.Now()}
+
+	// between looking hello up and saving it, another post takes hello and
+	// moves on to another title
+	var other models.Post
+	err := s.postsHandler.saveWithSlug(ctx, post, func(slug string) error {
+		decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), &other)
+		alice.mustDo("PATCH", "/posts/"+other.PostID.Hex(), `{"postTitle":"Goodbye"}`)
+		post.Slug = slug
+		return s.posts.InsertPost(ctx, post)
+	})
+	if err != nil || post.Slug != "hello" {
+		t.Fatalf("saveWithSlug: got %q, %v", post.Slug, err)
+	}
+
+	if other, _ = s.posts.GetPost(ctx, other.PostID); len(other.OldSlugs) != 0 {
+		t.Errorf("old slugs of the other post: got %v", other.OldSlugs)
+	}
+	var got models.Post
+	decode(t, alice.mustDo("GET", "/p/hello", ""), &got)
+	if got.PostID != post.PostID {
+		t.Errorf("GET /p/hello: got post %s, want %s", got.PostID.Hex(), post.PostID.Hex())
+	}
+}
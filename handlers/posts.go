@@ -1,73 +1,180 @@
 package handlers
 
 import (
+	"blogo/apierror"
+	"blogo/cache"
+	"blogo/counters"
+	"blogo/logging"
+	"blogo/markdown"
 	"blogo/models"
-	"encoding/json"
-	"log"
+	"blogo/search"
+	"blogo/store"
+	"fmt"
+	"math"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/net/context"
 )
 
 type PostsHandler struct {
-	ctx         context.Context
-	collection  *mongo.Collection
+	posts       store.PostStore
+	revisions   store.RevisionStore
+	tags        store.TagStore
+	reactions   store.ReactionStore
+	search      search.Engine
 	redisClient *redis.Client
+	cache       *cache.Cache
+	counters    *counters.Buffer
+	markdown    *markdown.Renderer
+	// html caches rendered content by post revision.
+	html *cache.Cache
 }
 
-func NewPostsHandlers(ctx context.Context, collection *mongo.Collection, redisClient *redis.Client) *PostsHandler {
-	return &PostsHandler{
-		ctx:         ctx,
-		collection:  collection,
+// NewPostsHandlers creates the post handlers. redisClient may be nil, in
+// which case responses are never cached and reactions are counted in
+// MongoDB right away instead of by the flusher of Counters.
+func NewPostsHandlers(posts store.PostStore, revisions store.RevisionStore, tags store.TagStore, reactions store.ReactionStore, searchEngine search.Engine, redisClient *redis.Client) *PostsHandler {
+	handler := &PostsHandler{
+		posts:       posts,
+		revisions:   revisions,
+		tags:        tags,
+		reactions:   reactions,
+		search:      searchEngine,
 		redisClient: redisClient,
+		cache:       cache.New(redisClient, postsCacheName),
+		markdown:    markdown.New(),
+		html:        cache.New(redisClient, htmlCacheName),
 	}
+	if redisClient != nil {
+		handler.counters = counters.NewBuffer(redisClient, postsCountersName, handler.flushReactions)
+		handler.counters.OnFlushed(handler.reactionsFlushed)
+	}
+	return handler
+}
+
+// Counters returns the buffer of unflushed reaction counts, or nil when
+// there is no Redis.
+func (handler *PostsHandler) Counters() *counters.Buffer {
+	return handler.counters
 }
 
+// Cached responses are grouped so that a write can drop exactly what it
+// affects: a single post lives in its own group, all listing pages and the
+// random post pool share postsPagesGroup and search results share
+// postsSearchGroup.
+const (
+	postsCacheName   = "posts"
+	postsPagesGroup  = "posts:pages"
+	postsSearchGroup = "posts:search"
+	postsPageTTL     = 5 * time.Minute
+	postTTL          = 10 * time.Minute
+	postsSearchTTL   = time.Minute
+	randomPoolTTL    = time.Minute
+	randomPoolSize   = 50
+
+	postsCountersName = "counters:posts"
+
+	// Rendered content is keyed by revision, so entries never go stale
+	// and are left to expire.
+	htmlCacheName = "html"
+	htmlGroup     = "posts:html"
+	htmlTTL       = 24 * time.Hour
+)
+
 // swagger:operation GET /posts post listPosts
-// Return lists of posts
+// Return one page of posts
 // ---
 // produces:
 // - application/json
+// parameters:
+//   - name: limit
+//     in: query
+//     description: page size, at most 100
+//     type: integer
+//   - name: cursor
+//     in: query
+//     description: nextCursor of the previous page
+//     type: string
+//   - name: sort
+//     in: query
+//     description: created, updated or thumbs, always descending
+//     type: string
+//   - name: tag
+//     in: query
+//     type: string
+//   - name: author
+//     in: query
+//     type: string
+//   - name: from
+//     in: query
+//     description: earliest creation time, RFC 3339 or YYYY-MM-DD
+//     type: string
+//   - name: to
+//     in: query
+//     description: creation time before which posts were created
+//     type: string
+//   - name: status
+//     in: query
+//     description: published (default), or draft, scheduled or archived together with author set to the signed in user
+//     type: string
 // responses:
 //  '200':
 //   description: Successful operation
-func (handler *PostsHandler) ListPostsHandler(c *gin.Context) {
-	val, err := handler.redisClient.Get("posts").Result()
-	if err == redis.Nil {
-		log.Printf("Request to MongoDB")
-		cur, err := handler.collection.Find(handler.ctx, bson.M{})
-		if err != nil {
-			log.Printf("Request to Mongo Failed")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+//  '400':
+//   description: Invalid query parameter or cursor
+//  '403':
+//   description: Listing posts that are not published of another user
+func (handler *PostsHandler) ListPostsHandler(c *gin.Context) error {
+	query, err := parsePostQuery(c)
+	if err != nil {
+		return err
+	}
+	if query.Tag != "" {
+		if query.Tag, err = handler.resolveTag(c.Request.Context(), query.Tag); err != nil {
+			return err
 		}
-		defer cur.Close(handler.ctx)
+	}
 
-		posts := make([]models.Post, 0)
-		for cur.Next(handler.ctx) {
-			var post models.Post
-			cur.Decode(&post)
-			posts = append(posts, post)
-		}
+	return handler.listPosts(c, query)
+}
+
+// listPosts responds with the page selected by query.
+func (handler *PostsHandler) listPosts(c *gin.Context, query store.PostQuery) error {
+	page, err := handler.postsPage(c, query)
+	if err != nil {
+		return err
+	}
+
+	handler.annotatePage(c, page)
+	c.JSON(http.StatusOK, page)
+	return nil
+}
 
-		data, _ := json.Marshal(posts)
-		handler.redisClient.Set("posts_in_redis", string(data), 0)
-		c.JSON(http.StatusOK, posts)
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	} else {
-		log.Printf("Request to redis")
-		posts := make([]models.Post, 0)
-		json.Unmarshal([]byte(val), &posts)
-		c.JSON(http.StatusOK, posts)
+// postsPage reads the page selected by query, from the cache when possible.
+func (handler *PostsHandler) postsPage(c *gin.Context, query store.PostQuery) (store.PostPage, error) {
+	var page store.PostPage
+	err := handler.cache.Get(postsPagesGroup, pageCacheKey(query), postsPageTTL, &page, func() (interface{}, error) {
+		logging.FromContext(c.Request.Context()).Debug("Posts page not cached, reading MongoDB", "query", pageCacheKey(query))
+		return handler.posts.ListPosts(c.Request.Context(), query)
+	})
+	return page, err
+}
+
+// annotatePage is annotate for all posts of a page.
+func (handler *PostsHandler) annotatePage(c *gin.Context, page store.PostPage) {
+	posts := make([]*models.Post, 0, len(page.Posts))
+	for i := range page.Posts {
+		posts = append(posts, &page.Posts[i])
 	}
+	handler.annotate(c, posts...)
 }
 
 // swagger:operation POST /posts post newPost
@@ -77,57 +184,74 @@ func (handler *PostsHandler) ListPostsHandler(c *gin.Context) {
 // - application/json
 // responses:
 //  '200':
-//   description: Successful operation
+//   description: Successful operation. Posts are published unless status is draft, or scheduled with a publishAt time. The slug of the post is derived from its title.
+//  '400':
+//   description: Invalid post
 //  '500':
-//   description: Decode input post error or insertion error
-func (handler *PostsHandler) NewPostHandler(c *gin.Context) {
+//   description: Insertion error
+func (handler *PostsHandler) NewPostHandler(c *gin.Context) error {
 	var post models.Post
-	if err := c.ShouldBindJSON(&post); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := bindJSON(c, &post); err != nil {
+		return err
+	}
+	post.Status = post.EffectiveStatus()
+	if err := checkSchedule(&post); err != nil {
+		return err
+	}
+	post.Username = currentUsername(c) // the session decides who the author is
+	tags, err := handler.normalizeTags(c.Request.Context(), post.Tags)
+	if err != nil {
+		return err
 	}
+	post.Tags = tags
 	post.NumOfThumb = 0
+	post.Reactions = nil
+	post.Trash = nil
 	post.PostID = primitive.NewObjectID()
 	post.CreatedTime = time.Now()
 	post.LastUpdatedTime = post.CreatedTime
+	post.OldSlugs = nil
 
-	_, err := handler.collection.InsertOne(handler.ctx, post)
-
+	err = handler.saveWithSlug(c.Request.Context(), post, func(slug string) error {
+		post.Slug = slug
+		return handler.posts.InsertPost(c.Request.Context(), post)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
-	log.Println("Delete redis cache")
-	handler.redisClient.Del("posts_in_redis")
+	handler.indexPost(c.Request.Context(), post)
+	handler.invalidatePosts()
 	c.JSON(http.StatusOK, post)
+	return nil
 }
 
 // swagger:operation GET /random-post post getOneRandomPost
-// Return a post sampled randomly
+// Return a post sampled randomly from a pool that is refreshed every minute
 // ---
 // produces:
 // - application/json
 // responses:
 //  '200':
 //   description: Successful operation
-func (handler *PostsHandler) GetOneRandomPost(c *gin.Context) {
-	// retrieve parameter id and search in database
-	pipeline := []bson.D{{{"$sample", bson.D{{"size", 1}}}}}
-	// TODO: use redis!
-
-	cur, err := handler.collection.Aggregate(handler.ctx, pipeline)
+//  '404':
+//   description: There is no post
+func (handler *PostsHandler) GetOneRandomPost(c *gin.Context) error {
+	var pool []models.Post
+	err := handler.cache.Get(postsPagesGroup, "random", randomPoolTTL, &pool, func() (interface{}, error) {
+		return handler.posts.SamplePosts(c.Request.Context(), randomPoolSize)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return err
 	}
-	posts := make([]models.Post, 0)
-	for cur.Next(handler.ctx) {
-		var post models.Post
-		cur.Decode(&post)
-		posts = append(posts, post)
+	if len(pool) == 0 {
+		return store.ErrNotFound
 	}
 
-	c.JSON(http.StatusOK, posts[0])
+	post := pool[rand.Intn(len(pool))]
+	handler.annotate(c, &post)
+	c.JSON(http.StatusOK, post)
+	return nil
 }
 
 // swagger:operation GET /posts/{id} post viewPost
@@ -135,45 +259,69 @@ func (handler *PostsHandler) GetOneRandomPost(c *gin.Context) {
 // ---
 // produces:
 // - application/json
+// parameters:
+//   - name: format
+//     in: query
+//     description: markdown (default), or html to add the content rendered to sanitized HTML as contentHtml
+//     type: string
 // responses:
 //  '200':
 //   description: Successful operation
 //  '400':
-//   description: Invalid post ID
+//   description: Invalid post ID or format
 //  '404':
-//   description: post with provided ID not found
-func (handler *PostsHandler) ViewPostHandler(c *gin.Context) {
-	// retrieve parameter id and search in database
-	postIDString := c.Param("id")
-	postID, err := primitive.ObjectIDFromHex(postIDString)
+//   description: post with provided ID not found, or a draft of another user
+func (handler *PostsHandler) ViewPostHandler(c *gin.Context) error {
+	postID, err := paramID(c, "id")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
-	// TODO: use redis!
-
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"_id": postID,
+	var post models.Post
+	err = handler.cache.Get(postGroup(postID), "", postTTL, &post, func() (interface{}, error) {
+		return handler.posts.GetPost(c.Request.Context(), postID)
 	})
-	if cur.Err() != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": cur.Err().Error()})
-		return
+	if err != nil {
+		return err
+	}
+	if !canView(c, post) {
+		return store.ErrNotFound
 	}
+	return handler.viewPost(c, post)
+}
 
-	// decode the post into go struct
-	var post models.Post
-	err = cur.Decode(&post)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// viewPost writes post in the format asked for by the format query
+// parameter.
+func (handler *PostsHandler) viewPost(c *gin.Context, post models.Post) error {
+	var err error
+	switch c.DefaultQuery("format", "markdown") {
+	case "markdown":
+	case "html":
+		if post.ContentHTML, err = handler.renderContent(post); err != nil {
+			return err
+		}
+	default:
+		return apierror.New(apierror.CodeBadRequest, "format must be markdown or html")
 	}
 
+	handler.annotate(c, &post)
 	c.JSON(http.StatusOK, post)
+	return nil
+}
+
+// renderContent returns the content of post as sanitized HTML, rendering it
+// only once per revision.
+func (handler *PostsHandler) renderContent(post models.Post) (string, error) {
+	key := post.PostID.Hex() + ":" + strconv.FormatInt(post.LastUpdatedTime.UnixNano(), 10)
+	var html string
+	err := handler.html.Get(htmlGroup, key, htmlTTL, &html, func() (interface{}, error) {
+		return handler.markdown.Render(post.Content)
+	})
+	return html, err
 }
 
 // swagger:operation DELETE /posts/{id} post deletePost
-// Delete a post given its ID
+// Move a post to the trash, from where it can be restored until it is purged
 // ---
 // produces:
 // - application/json
@@ -186,25 +334,36 @@ func (handler *PostsHandler) ViewPostHandler(c *gin.Context) {
 // responses:
 //   '200':
 //     description: Successful operation
-//   '404':
+//   '400':
 //     description: Invalid post ID
-func (handler *PostsHandler) DeletePostHandler(c *gin.Context) {
-	id := c.Param("id")
-	objectid, _ := primitive.ObjectIDFromHex(id)
-	_, err := handler.collection.DeleteOne(handler.ctx, bson.M{
-		"_id": objectid,
-	})
-
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) DeletePostHandler(c *gin.Context) error {
+	post, err := handler.loadPost(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return err
+	}
+	if err := authorize(c, post.Username); err != nil {
+		return err
 	}
 
-	handler.redisClient.Del("posts_in_redis")
+	trash := models.Trash{DeletedBy: currentUsername(c), DeletedTime: time.Now()}
+	if err := handler.posts.TrashPost(c.Request.Context(), post.PostID, trash); err != nil {
+		return err
+	}
+
+	if err := handler.search.Remove(c.Request.Context(), post.PostID); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Remove post from search index failed", "post", post.PostID.Hex(), "error", err)
+	}
+	handler.invalidatePosts(post.PostID)
 	c.JSON(http.StatusOK, gin.H{"deleteResult": "success"})
+	return nil
 }
 
 // swagger:operation GET /post/search/{title} post searchPost
-// Search a post given its title
+// Search posts for the words of a title. Deprecated in favour of /search.
 // ---
 // produces:
 // - application/json
@@ -219,30 +378,29 @@ func (handler *PostsHandler) DeletePostHandler(c *gin.Context) {
 //     description: Successful operation
 //   '404':
 //     description: Invalid post title
-func (handler *PostsHandler) SearchPostHandler(c *gin.Context) {
+func (handler *PostsHandler) SearchPostHandler(c *gin.Context) error {
 	title := c.Param("title")
 
-	// TODO: use redis!
-
-	cur, err := handler.collection.Find(handler.ctx, bson.M{
-		"postTitle": title,
+	var result search.Result
+	err := handler.cache.Get(postsSearchGroup, "title:"+title, postsSearchTTL, &result, func() (interface{}, error) {
+		return handler.search.Search(c.Request.Context(), search.Query{Text: title})
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
-	posts := make([]models.Post, 0)
-	for cur.Next(handler.ctx) {
-		var post models.Post
-		cur.Decode(&post)
-		posts = append(posts, post)
+	posts := make([]models.Post, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		posts = append(posts, hit.Post)
 	}
+	handler.annotatePage(c, store.PostPage{Posts: posts})
 
 	c.JSON(http.StatusOK, posts)
+	return nil
 }
 
 // swagger:operation POST /post/thumbup/{id} post thumbupPost
-// Give a post specified by id a thumb up
+// Like a post specified by id. Deprecated in favour of PUT
+// /posts/{id}/reactions.
 // ---
 // produces:
 // - application/json
@@ -255,38 +413,179 @@ func (handler *PostsHandler) SearchPostHandler(c *gin.Context) {
 // responses:
 //   '200':
 //     description: Successful operation
-//   '404':
+//   '400':
 //     description: Invalid post id
-func (handler *PostsHandler) ThumbupPostHandler(c *gin.Context) {
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) ThumbupPostHandler(c *gin.Context) error {
+	return handler.reactToPost(c, models.ReactionLike, func(models.Post) {
+		c.JSON(http.StatusOK, gin.H{"thumbupResult": "success"})
+	})
+}
+
+// swagger:operation GET /search post fullTextSearch
+// Search the title, tags and content of posts, best matches first
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: q
+//     in: query
+//     description: words to search for
+//     required: true
+//     type: string
+//   - name: tag
+//     in: query
+//     type: string
+//   - name: author
+//     in: query
+//     type: string
+//   - name: limit
+//     in: query
+//     description: page size, at most 100
+//     type: integer
+//   - name: offset
+//     in: query
+//     description: number of hits to skip
+//     type: integer
+// responses:
+//  '200':
+//   description: Successful operation
+//  '400':
+//   description: Missing q or invalid paging parameter
+func (handler *PostsHandler) SearchHandler(c *gin.Context) error {
+	query := search.Query{
+		Text:   strings.TrimSpace(c.Query("q")),
+		Tag:    c.Query("tag"),
+		Author: c.Query("author"),
+	}
+	if query.Text == "" {
+		return apierror.New(apierror.CodeBadRequest, "q is required")
+	}
 
-	id := c.Param("id")
-	objectid, _ := primitive.ObjectIDFromHex(id)
+	var err error
+	if query.Tag != "" {
+		if query.Tag, err = handler.resolveTag(c.Request.Context(), query.Tag); err != nil {
+			return err
+		}
+	}
+	if query.Limit, err = intQuery(c, "limit", 0, search.MaxLimit); err != nil {
+		return err
+	}
+	if query.Offset, err = intQuery(c, "offset", 0, math.MaxInt32); err != nil {
+		return err
+	}
 
-	// find the comment
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"_id": objectid,
+	var result search.Result
+	err = handler.cache.Get(postsSearchGroup, searchCacheKey(query), postsSearchTTL, &result, func() (interface{}, error) {
+		return handler.search.Search(c.Request.Context(), query)
 	})
-	if cur.Err() != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": cur.Err().Error()})
-		return
+	if err != nil {
+		return err
 	}
+	posts := make([]*models.Post, 0, len(result.Hits))
+	for i := range result.Hits {
+		posts = append(posts, &result.Hits[i].Post)
+	}
+	handler.annotate(c, posts...)
+	c.JSON(http.StatusOK, result)
+	return nil
+}
 
-	// modify the post
-	var post models.Post
-	err := cur.Decode(&post)
-	if err != nil { // decode error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// indexPost hands a new or edited post to the search engine.
+func (handler *PostsHandler) indexPost(ctx context.Context, post models.Post) {
+	if err := handler.search.Index(ctx, post); err != nil {
+		logging.FromContext(ctx).Error("Index post failed", "post", post.PostID.Hex(), "error", err)
 	}
+}
 
-	num := post.NumOfThumb
-	_, err = handler.collection.UpdateByID(handler.ctx, objectid, bson.M{
-		"$set": bson.D{{"postNumOfThumb", num + 1}},
-	})
+// invalidatePosts drops the cached listings and search results and the
+// cached copies of the posts ids after a write.
+func (handler *PostsHandler) invalidatePosts(ids ...primitive.ObjectID) {
+	groups := []string{postsPagesGroup, postsSearchGroup}
+	for _, id := range ids {
+		groups = append(groups, postGroup(id))
+	}
+	handler.cache.Invalidate(groups...)
+}
+
+// postGroup is the cache group of a single post.
+func postGroup(id primitive.ObjectID) string {
+	return "post:" + id.Hex()
+}
+
+// parsePostQuery reads the listing parameters of GET /posts. Invalid
+// parameters are bad requests.
+func parsePostQuery(c *gin.Context) (store.PostQuery, error) {
+	query := store.PostQuery{
+		Cursor: c.Query("cursor"),
+		Sort:   store.PostSort(c.DefaultQuery("sort", string(store.SortCreated))),
+		Tag:    c.Query("tag"),
+		Author: c.Query("author"),
+		Status: models.PostStatus(c.DefaultQuery("status", string(models.StatusPublished))),
+	}
+	if !query.Sort.Valid() {
+		return query, apierror.New(apierror.CodeBadRequest, "unknown sort %q", query.Sort)
+	}
+	switch query.Status {
+	case models.StatusPublished:
+	case models.StatusDraft, models.StatusScheduled, models.StatusArchived:
+		if username := currentUsername(c); username == "" || query.Author != username {
+			return query, apierror.New(apierror.CodeForbidden, "only your own %s posts can be listed, set author to your username", query.Status)
+		}
+	default:
+		return query, apierror.New(apierror.CodeBadRequest, "unknown status %q", query.Status)
+	}
 
-	if err != nil { // update error
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+	var err error
+	if query.Limit, err = intQuery(c, "limit", 1, store.MaxPageSize); err != nil {
+		return query, err
 	}
-	c.JSON(http.StatusOK, gin.H{"thumbupResult": "success"})
+	if query.From, err = parseTime(c.Query("from")); err != nil {
+		return query, apierror.New(apierror.CodeBadRequest, "invalid from: %v", err)
+	}
+	if query.To, err = parseTime(c.Query("to")); err != nil {
+		return query, apierror.New(apierror.CodeBadRequest, "invalid to: %v", err)
+	}
+	return query, nil
+}
+
+// intQuery reads an optional integer query parameter between min and max.
+// A missing parameter yields 0 and an invalid one is a bad request.
+func intQuery(c *gin.Context, name string, min, max int) (int, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, apierror.New(apierror.CodeBadRequest, "%s must be between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates. The empty string
+// yields the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// searchCacheKey identifies a search result in the cache.
+func searchCacheKey(q search.Query) string {
+	return strings.Join([]string{
+		url.QueryEscape(q.Text), q.Tag, q.Author, strconv.Itoa(q.Limit), strconv.Itoa(q.Offset),
+	}, "|")
+}
+
+// pageCacheKey identifies a listing page in the cache.
+func pageCacheKey(q store.PostQuery) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s|%s|%s|%s",
+		q.Status, q.Sort, q.Limit, q.Cursor, url.QueryEscape(q.Tag), url.QueryEscape(q.Author),
+		q.From.Format(time.RFC3339Nano), q.To.Format(time.RFC3339Nano))
 }
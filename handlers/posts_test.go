@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/search"
+	"blogo/store"
+	"net/http"
+	"testing"
+)
+
+func TestPostLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	anonymous := s.client(t)
+
+	if code, _ := anonymous.do("POST", "/posts", `{"postTitle":"Anonymous"}`); code != http.StatusUnauthorized {
+		t.Fatalf("POST /posts without session: got %d, want 401", code)
+	}
+
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"username":"alice","postTitle":"Hello","postTags":["go"],"postContent":"First"}`), "postID")
+
+	var page store.PostPage
+	decode(t, anonymous.mustDo("GET", "/posts", ""), &page)
+	if len(page.Posts) != 1 || page.Posts[0].Title != "Hello" {
+		t.Fatalf("GET /posts: got %+v", page)
+	}
+
+	var post models.Post
+	decode(t, anonymous.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.Content != "First" || post.CreatedTime.IsZero() {
+		t.Fatalf("GET /posts/%s: got %+v", id, post)
+	}
+	if code, _ := anonymous.do("GET", "/posts/not-an-id", ""); code != http.StatusBadRequest {
+		t.Errorf("GET /posts/not-an-id: got %d, want 400", code)
+	}
+
+	alice.mustDo("POST", "/posts/thumbup/"+id, "")
+	decode(t, anonymous.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.NumOfThumb != 1 {
+		t.Errorf("thumbs after a thumb up: got %d, want 1", post.NumOfThumb)
+	}
+
+	var posts []models.Post
+	decode(t, anonymous.mustDo("GET", "/posts/search/Hello", ""), &posts)
+	if len(posts) != 1 {
+		t.Errorf("GET /posts/search/Hello: got %d posts, want 1", len(posts))
+	}
+
+	alice.mustDo("DELETE", "/posts/"+id, "")
+	if code, _ := anonymous.do("GET", "/posts/"+id, ""); code != http.StatusNotFound {
+		t.Errorf("GET /posts/%s after delete: got %d, want 404", id, code)
+	}
+	if code, _ := anonymous.do("GET", "/random-post", ""); code != http.StatusNotFound {
+		t.Errorf("GET /random-post without posts: got %d, want 404", code)
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+
+	if code, _ := alice.do("POST", "/signup", `{"username":"alice","password":"other123"}`); code != http.StatusConflict {
+		t.Errorf("second sign up as alice: got %d, want 409", code)
+	}
+
+	again := s.client(t)
+	if code, _ := again.do("POST", "/signin", `{"username":"alice","password":"wrong"}`); code != http.StatusUnauthorized {
+		t.Errorf("sign in with a wrong password: got %d, want 401", code)
+	}
+	again.mustDo("POST", "/signin", `{"username":"alice","password":"passw0rd!"}`)
+	again.mustDo("POST", "/posts", `{"username":"alice","postTitle":"Signed in"}`)
+}
+
+func TestPostOwnership(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+
+	body := alice.mustDo("POST", "/posts", `{"username":"bob","postTitle":"Hello"}`)
+	if got := field(t, body, "username"); got != "alice" {
+		t.Fatalf("post author: got %q, want alice", got)
+	}
+	id := field(t, body, "postID")
+
+	if code, _ := bob.do("DELETE", "/posts/"+id, ""); code != http.StatusForbidden {
+		t.Errorf("DELETE by another user: got %d, want 403", code)
+	}
+	if code, _ := bob.do("PUT", "/posts/"+id, `{"postTitle":"Mine"}`); code != http.StatusForbidden {
+		t.Errorf("PUT by another user: got %d, want 403", code)
+	}
+	if code, _ := alice.do("DELETE", "/posts/not-an-id", ""); code != http.StatusBadRequest {
+		t.Errorf("DELETE /posts/not-an-id: got %d, want 400", code)
+	}
+	alice.mustDo("DELETE", "/posts/"+id, "")
+}
+
+func TestListPostsPages(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	for i := 0; i < 5; i++ {
+		alice.mustDo("POST", "/posts", `{"postTitle":"Alice","postTags":["go"]}`)
+	}
+	bob.mustDo("POST", "/posts", `{"postTitle":"Bob","postTags":["db"]}`)
+
+	var page store.PostPage
+	seen := map[string]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		decode(t, alice.mustDo("GET", "/posts?limit=2&cursor="+cursor, ""), &page)
+		if page.Total != 6 {
+			t.Fatalf("total: got %d, want 6", page.Total)
+		}
+		for _, post := range page.Posts {
+			if seen[post.PostID.Hex()] {
+				t.Fatalf("post %s on two pages", post.PostID.Hex())
+			}
+			seen[post.PostID.Hex()] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 6 {
+		t.Errorf("paged through %d posts, want 6", len(seen))
+	}
+
+	decode(t, alice.mustDo("GET", "/posts?author=bob", ""), &page)
+	if len(page.Posts) != 1 || page.Posts[0].Username != "bob" {
+		t.Errorf("author filter: got %+v", page.Posts)
+	}
+	decode(t, alice.mustDo("GET", "/posts?tag=go", ""), &page)
+	if page.Total != 5 {
+		t.Errorf("tag filter: got %d posts, want 5", page.Total)
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "sort=title", "cursor=garbage", "from=yesterday"} {
+		if code, _ := alice.do("GET", "/posts?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("GET /posts?%s: got %d, want 400", query, code)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Gophers","postContent":"All about gophers"}`), "postID")
+	alice.mustDo("POST", "/posts", `{"postTitle":"Databases"}`)
+
+	var result search.Result
+	decode(t, alice.mustDo("GET", "/search?q=gophers", ""), &result)
+	if result.Total != 1 || result.Hits[0].Highlights.Title != "<mark>Gophers</mark>" {
+		t.Fatalf("GET /search?q=gophers: got %+v", result)
+	}
+
+	alice.mustDo("PATCH", "/posts/"+id, `{"postTitle":"Moles","postContent":"All about moles"}`)
+	decode(t, alice.mustDo("GET", "/search?q=gophers", ""), &result)
+	if result.Total != 0 {
+		t.Errorf("search finds the old title after an edit: %+v", result)
+	}
+	alice.mustDo("DELETE", "/posts/"+id, "")
+	decode(t, alice.mustDo("GET", "/search?q=moles", ""), &result)
+	if result.Total != 0 {
+		t.Errorf("search finds a deleted post: %+v", result)
+	}
+
+	for _, query := range []string{"", "q=x&limit=101", "q=x&offset=-1"} {
+		if code, _ := alice.do("GET", "/search?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("GET /search?%s: got %d, want 400", query, code)
+		}
+	}
+}
+
+func TestCachedPosts(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeSession, newTestRedis(t))
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Gophers"}`), "postID")
+
+	var post models.Post
+	var page store.PostPage
+	var result search.Result
+	// fill the caches
+	decode(t, alice.mustDo("GET", "/posts/"+id, ""), &post)
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	decode(t, alice.mustDo("GET", "/search?q=gophers", ""), &result)
+
+	alice.mustDo("PATCH", "/posts/"+id, `{"postTitle":"Moles"}`)
+	decode(t, alice.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.Title != "Moles" {
+		t.Errorf("cached post after an edit: got %q", post.Title)
+	}
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	if len(page.Posts) != 1 || page.Posts[0].Title != "Moles" {
+		t.Errorf("cached page after an edit: got %+v", page.Posts)
+	}
+	decode(t, alice.mustDo("GET", "/search?q=gophers", ""), &result)
+	if result.Total != 0 {
+		t.Errorf("cached search after an edit: got %+v", result)
+	}
+
+	alice.mustDo("POST", "/posts", `{"postTitle":"Second"}`)
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	if page.Total != 2 {
+		t.Errorf("cached page after a new post: got %d posts, want 2", page.Total)
+	}
+
+	alice.mustDo("DELETE", "/posts/"+id, "")
+	if code, _ := alice.do("GET", "/posts/"+id, ""); code != http.StatusNotFound {
+		t.Errorf("cached post after delete: got %d, want 404", code)
+	}
+}
+
+func TestPostFormat(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello","postContent":"**bold** <script>x</script>"}`), "postID")
+
+	var post models.Post
+	decode(t, alice.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.ContentHTML != "" {
+		t.Errorf("default format renders HTML: %q", post.ContentHTML)
+	}
+	decode(t, alice.mustDo("GET", "/posts/"+id+"?format=html", ""), &post)
+	if post.ContentHTML != "<p><strong>bold</strong> </p>\n" {
+		t.Errorf("contentHtml: got %q", post.ContentHTML)
+	}
+	if code, _ := alice.do("GET", "/posts/"+id+"?format=pdf", ""); code != http.StatusBadRequest {
+		t.Errorf("unknown format: got %d, want 400", code)
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/logging"
+	"blogo/models"
+	"blogo/store"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// swagger:operation GET /posts/{id}/reactions post listPostReactions
+// Return the reaction counters of a post and who reacted how
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) ListPostReactionsHandler(c *gin.Context) error {
+	post, err := handler.loadPost(c)
+	if err != nil {
+		return err
+	}
+
+	reactions, err := handler.reactions.ListReactions(c.Request.Context(), post.PostID)
+	if err != nil {
+		return err
+	}
+	handler.annotate(c, &post)
+
+	body := reactionSummary(post.Reactions, post.NumOfThumb, post.Reaction)
+	body["users"] = reactions
+	c.JSON(http.StatusOK, body)
+	return nil
+}
+
+// swagger:operation PUT /posts/{id}/reactions post reactToPost
+// React to a post, replacing an earlier reaction of the signed in user
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID or reaction type
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) ReactPostHandler(c *gin.Context) error {
+	reactionType, err := bindReaction(c)
+	if err != nil {
+		return err
+	}
+	return handler.reactToPost(c, reactionType, func(post models.Post) {
+		c.JSON(http.StatusOK, reactionSummary(post.Reactions, post.NumOfThumb, reactionType))
+	})
+}
+
+// swagger:operation DELETE /posts/{id}/reactions post unreactToPost
+// Withdraw the reaction of the signed in user to a post
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation, also when there was no reaction
+//   '400':
+//     description: Invalid post ID
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) UnreactPostHandler(c *gin.Context) error {
+	return handler.reactToPost(c, "", func(post models.Post) {
+		c.JSON(http.StatusOK, reactionSummary(post.Reactions, post.NumOfThumb, ""))
+	})
+}
+
+// reactToPost sets the reaction of the signed in user to the post named by
+// the id path parameter, or removes it when reactionType is empty, and
+// hands the post with its new counters to respond.
+func (handler *PostsHandler) reactToPost(c *gin.Context, reactionType models.ReactionType, respond func(models.Post)) error {
+	post, err := handler.loadPost(c)
+	if err != nil {
+		return err
+	}
+
+	deltas, err := react(c.Request.Context(), handler.reactions, post.PostID, currentUsername(c), reactionType)
+	if err != nil {
+		return err
+	}
+	if len(deltas) > 0 {
+		if handler.counters != nil {
+			err = handler.counters.Add(post.PostID, deltas)
+		} else if err = handler.flushReactions(c.Request.Context(), post.PostID, deltas); err == nil {
+			handler.reactionsFlushed(c.Request.Context(), []primitive.ObjectID{post.PostID})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if handler.counters != nil {
+		handler.mergePending(c.Request.Context(), &post)
+	} else {
+		post.Reactions = addDeltas(post.Reactions, deltas)
+		post.NumOfThumb += sumDeltas(deltas)
+	}
+	respond(post)
+	return nil
+}
+
+// flushReactions writes reaction counter changes of a post to the database.
+// Changes to a post that was deleted meanwhile are dropped. The cached
+// copies of the post are left to the caller.
+func (handler *PostsHandler) flushReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	err := handler.posts.IncrementPostReactions(ctx, id, deltas)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// reactionsFlushed brings the cached copies of posts and their copies in
+// the search index up to date once new reaction counts are written.
+func (handler *PostsHandler) reactionsFlushed(ctx context.Context, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		post, err := handler.posts.GetPost(ctx, id)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("Reindex post failed", "post", id.Hex(), "error", err)
+			continue
+		}
+		handler.indexPost(ctx, post)
+	}
+	handler.invalidatePosts(ids...)
+}
+
+// flushReactions writes reaction counter changes of a comment to the
+// database. Changes to a comment that was deleted meanwhile are dropped.
+func (handler *CommentsHandler) flushReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	err := handler.comments.IncrementCommentReactions(ctx, id, deltas)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// annotate fills in what is not stored with posts: reactions counted but
+// not flushed yet and the reaction of the signed in user.
+func (handler *PostsHandler) annotate(c *gin.Context, posts ...*models.Post) {
+	if len(posts) == 0 {
+		return
+	}
+	handler.mergePending(c.Request.Context(), posts...)
+
+	username := currentUsername(c)
+	if username == "" {
+		return
+	}
+	ids := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.PostID)
+	}
+	reacted, err := handler.reactions.UserReactions(c.Request.Context(), username, ids)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Look up reactions failed", "error", err)
+		return
+	}
+	for _, post := range posts {
+		post.Reaction = reacted[post.PostID]
+		post.Reacted = post.Reaction != ""
+	}
+}
+
+// mergePending adds the reactions that are not flushed yet to the counters
+// of posts.
+func (handler *PostsHandler) mergePending(ctx context.Context, posts ...*models.Post) {
+	if handler.counters == nil {
+		return
+	}
+	ids := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.PostID)
+	}
+	pending, err := handler.counters.Pending(ids...)
+	if err != nil {
+		logging.FromContext(ctx).Error("Read pending reaction counts failed", "error", err)
+		return
+	}
+	for _, post := range posts {
+		if deltas, ok := pending[post.PostID]; ok {
+			post.Reactions = addDeltas(post.Reactions, deltas)
+			post.NumOfThumb += sumDeltas(deltas)
+		}
+	}
+}
+
+// swagger:operation PUT /comments/{commentid}/reactions comment reactToComment
+// React to a comment, replacing an earlier reaction of the signed in user
+// ---
+// produce:
+// - application/json
+// responses:
+//   '200':
+//     description: Success operation
+//   '400':
+//     description: Invalid comment ID or reaction type
+//   '404':
+//     description: comment with provided ID not found
+func (handler *CommentsHandler) ReactCommentHandler(c *gin.Context) error {
+	reactionType, err := bindReaction(c)
+	if err != nil {
+		return err
+	}
+	return handler.reactToComment(c, reactionType, func(comment models.Comment) {
+		c.JSON(http.StatusOK, reactionSummary(comment.Reactions, comment.NumOfThumb, reactionType))
+	})
+}
+
+// swagger:operation DELETE /comments/{commentid}/reactions comment unreactToComment
+// Withdraw the reaction of the signed in user to a comment
+// ---
+// produce:
+// - application/json
+// responses:
+//   '200':
+//     description: Success operation, also when there was no reaction
+//   '400':
+//     description: Invalid comment ID
+//   '404':
+//     description: comment with provided ID not found
+func (handler *CommentsHandler) UnreactCommentHandler(c *gin.Context) error {
+	return handler.reactToComment(c, "", func(comment models.Comment) {
+		c.JSON(http.StatusOK, reactionSummary(comment.Reactions, comment.NumOfThumb, ""))
+	})
+}
+
+// reactToComment is reactToPost for the comment named by the commentid path
+// parameter.
+func (handler *CommentsHandler) reactToComment(c *gin.Context, reactionType models.ReactionType, respond func(models.Comment)) error {
+	comment, err := handler.loadComment(c)
+	if err != nil {
+		return err
+	}
+
+	deltas, err := react(c.Request.Context(), handler.reactions, comment.CommentID, currentUsername(c), reactionType)
+	if err != nil {
+		return err
+	}
+	if len(deltas) > 0 {
+		if handler.counters != nil {
+			err = handler.counters.Add(comment.CommentID, deltas)
+		} else {
+			err = handler.flushReactions(c.Request.Context(), comment.CommentID, deltas)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if handler.counters != nil {
+		handler.mergePending(c.Request.Context(), &comment)
+	} else {
+		comment.Reactions = addDeltas(comment.Reactions, deltas)
+		comment.NumOfThumb += sumDeltas(deltas)
+	}
+	respond(comment)
+	return nil
+}
+
+// mergePending adds the reactions that are not flushed yet to the counters
+// of comments.
+func (handler *CommentsHandler) mergePending(ctx context.Context, comments ...*models.Comment) {
+	if handler.counters == nil || len(comments) == 0 {
+		return
+	}
+	ids := make([]primitive.ObjectID, 0, len(comments))
+	for _, comment := range comments {
+		ids = append(ids, comment.CommentID)
+	}
+	pending, err := handler.counters.Pending(ids...)
+	if err != nil {
+		logging.FromContext(ctx).Error("Read pending reaction counts failed", "error", err)
+		return
+	}
+	for _, comment := range comments {
+		if deltas, ok := pending[comment.CommentID]; ok {
+			comment.Reactions = addDeltas(comment.Reactions, deltas)
+			comment.NumOfThumb += sumDeltas(deltas)
+		}
+	}
+}
+
+// bindReaction reads the reaction type of the request body.
+func bindReaction(c *gin.Context) (models.ReactionType, error) {
+	var body struct {
+		Type models.ReactionType `json:"type"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		return "", err
+	}
+	if !body.Type.Valid() {
+		return "", apierror.New(apierror.CodeBadRequest, "type must be one of %v", models.ReactionTypes)
+	}
+	return body.Type, nil
+}
+
+// react records the reaction of username to target, or removes it when
+// reactionType is empty, and returns the resulting changes of the reaction
+// counters. Repeating a reaction changes nothing.
+func react(ctx context.Context, reactions store.ReactionStore, target primitive.ObjectID, username string, reactionType models.ReactionType) (map[models.ReactionType]int64, error) {
+	var previous models.ReactionType
+	var err error
+	if reactionType == "" {
+		previous, err = reactions.RemoveReaction(ctx, target, username)
+		if err == store.ErrNotFound {
+			err = nil
+		}
+	} else {
+		previous, err = reactions.SetReaction(ctx, models.Reaction{
+			ReactionID:  primitive.NewObjectID(),
+			TargetID:    target,
+			Username:    username,
+			Type:        reactionType,
+			CreatedTime: time.Now(),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := make(map[models.ReactionType]int64)
+	if previous != reactionType {
+		if previous != "" {
+			deltas[previous]--
+		}
+		if reactionType != "" {
+			deltas[reactionType]++
+		}
+	}
+	return deltas, nil
+}
+
+// reactionSummary describes the reaction counters of a target and the
+// reaction of the signed in user to it.
+func reactionSummary(counts map[models.ReactionType]int64, total int64, mine models.ReactionType) gin.H {
+	all := make(map[models.ReactionType]int64, len(models.ReactionTypes))
+	for _, reactionType := range models.ReactionTypes {
+		all[reactionType] = counts[reactionType]
+	}
+	body := gin.H{"reactions": all, "total": total, "reacted": mine != ""}
+	if mine != "" {
+		body["reaction"] = mine
+	}
+	return body
+}
+
+// addDeltas returns counts with deltas applied.
+func addDeltas(counts, deltas map[models.ReactionType]int64) map[models.ReactionType]int64 {
+	sum := make(map[models.ReactionType]int64, len(counts))
+	for reactionType, n := range counts {
+		sum[reactionType] = n
+	}
+	for reactionType, delta := range deltas {
+		sum[reactionType] += delta
+	}
+	return sum
+}
+
+// sumDeltas returns the change of the total that deltas cause.
+func sumDeltas(deltas map[models.ReactionType]int64) int64 {
+	var sum int64
+	for _, delta := range deltas {
+		sum += delta
+	}
+	return sum
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"blogo/counters"
+	"blogo/models"
+	"blogo/search"
+	"blogo/store"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// reactionBody is the body of the reaction endpoints.
+type reactionBody struct {
+	Reactions map[models.ReactionType]int64 `json:"reactions"`
+	Total     int64                         `json:"total"`
+	Reacted   bool                          `json:"reacted"`
+	Reaction  models.ReactionType           `json:"reaction"`
+	Users     []models.Reaction             `json:"users"`
+}
+
+func TestPostReactions(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+
+	var body reactionBody
+	decode(t, alice.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"like"}`), &body)
+	if body.Total != 1 || body.Reactions[models.ReactionLike] != 1 || body.Reaction != models.ReactionLike {
+		t.Errorf("first reaction: got %+v", body)
+	}
+	// reacting again with the same type changes nothing
+	alice.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"like"}`)
+	bob.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"funny"}`)
+	// a new type replaces the old one
+	decode(t, alice.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"insightful"}`), &body)
+	if body.Total != 2 || body.Reactions[models.ReactionLike] != 0 || body.Reactions[models.ReactionInsightful] != 1 {
+		t.Errorf("changed reaction: got %+v", body)
+	}
+
+	var post models.Post
+	decode(t, bob.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.NumOfThumb != 2 || post.Reactions[models.ReactionFunny] != 1 || !post.Reacted || post.Reaction != models.ReactionFunny {
+		t.Errorf("post seen by bob: got %+v", post)
+	}
+	decode(t, s.client(t).mustDo("GET", "/posts/"+id, ""), &post)
+	if post.Reacted {
+		t.Error("anonymous viewer is marked as having reacted")
+	}
+
+	decode(t, s.client(t).mustDo("GET", "/posts/"+id+"/reactions", ""), &body)
+	if len(body.Users) != 2 || body.Total != 2 {
+		t.Errorf("GET reactions: got %+v", body)
+	}
+	var result search.Result
+	decode(t, s.client(t).mustDo("GET", "/search?q=hello", ""), &result)
+	if len(result.Hits) != 1 || result.Hits[0].Post.NumOfThumb != 2 {
+		t.Errorf("search hits: got %+v", result.Hits)
+	}
+
+	decode(t, bob.mustDo("DELETE", "/posts/"+id+"/reactions", ""), &body)
+	if body.Total != 1 || body.Reacted {
+		t.Errorf("removed reaction: got %+v", body)
+	}
+	// removing twice is harmless
+	decode(t, bob.mustDo("DELETE", "/posts/"+id+"/reactions", ""), &body)
+	if body.Total != 1 {
+		t.Errorf("removed reaction twice: got %+v", body)
+	}
+
+	if code, _ := alice.do("PUT", "/posts/"+id+"/reactions", `{"type":"angry"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown reaction type: got %d, want 400", code)
+	}
+	if code, _ := s.client(t).do("PUT", "/posts/"+id+"/reactions", `{"type":"like"}`); code != http.StatusUnauthorized {
+		t.Errorf("anonymous reaction: got %d, want 401", code)
+	}
+}
+
+func TestCommentReactions(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	commentID := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"Nice"}`), "commentID")
+
+	// the legacy thumb up is a like
+	alice.mustDo("POST", "/comments/thumbup/"+commentID, "")
+	var body reactionBody
+	decode(t, alice.mustDo("PUT", "/comments/"+commentID+"/reactions", `{"type":"funny"}`), &body)
+	if body.Total != 1 || body.Reactions[models.ReactionLike] != 0 || body.Reactions[models.ReactionFunny] != 1 {
+		t.Errorf("comment reaction: got %+v", body)
+	}
+
+	var comments []models.Comment
+	decode(t, alice.mustDo("GET", "/comments/"+postID, ""), &comments)
+	if len(comments) != 1 || comments[0].NumOfThumb != 1 || comments[0].Reactions[models.ReactionFunny] != 1 {
+		t.Errorf("comment counters: got %+v", comments)
+	}
+
+	alice.mustDo("DELETE", "/comments/"+commentID+"/reactions", "")
+	decode(t, alice.mustDo("GET", "/comments/"+postID, ""), &comments)
+	if comments[0].NumOfThumb != 0 {
+		t.Errorf("comment counters after removal: got %+v", comments[0])
+	}
+}
+
+func TestBufferedReactions(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeSession, newTestRedis(t))
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	commentID := field(t, alice.mustDo("POST", "/comments/"+id, `{"commentContent":"Nice"}`), "commentID")
+
+	alice.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"like"}`)
+	alice.mustDo("PUT", "/comments/"+commentID+"/reactions", `{"type":"funny"}`)
+
+	// the store lags behind, but readers see the buffered counts
+	stored, _ := s.posts.GetPost(context.Background(), mustObjectID(t, id))
+	if stored.NumOfThumb != 0 {
+		t.Errorf("store has %d reactions before a flush, want 0", stored.NumOfThumb)
+	}
+	var post models.Post
+	decode(t, alice.mustDo("GET", "/posts/"+id, ""), &post)
+	if post.NumOfThumb != 1 || post.Reactions[models.ReactionLike] != 1 {
+		t.Errorf("post before a flush: got %+v", post)
+	}
+	var page store.PostPage
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	if len(page.Posts) != 1 || page.Posts[0].NumOfThumb != 1 {
+		t.Errorf("listing before a flush: got %+v", page.Posts)
+	}
+
+	ctx := context.Background()
+	if err := s.postsHandler.Counters().FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.commentsHandler.Counters().FlushAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = s.posts.GetPost(ctx, mustObjectID(t, id))
+	if stored.NumOfThumb != 1 || stored.Reactions[models.ReactionLike] != 1 {
+		t.Errorf("stored post after a flush: got %+v", stored)
+	}
+	var comments []models.Comment
+	decode(t, alice.mustDo("GET", "/comments/"+id, ""), &comments)
+	if len(comments) != 1 || comments[0].NumOfThumb != 1 {
+		t.Errorf("comments after a flush: got %+v", comments)
+	}
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	if len(page.Posts) != 1 || page.Posts[0].NumOfThumb != 1 {
+		t.Errorf("listing after a flush counts the reaction twice or not at all: got %+v", page.Posts)
+	}
+	var result search.Result
+	decode(t, alice.mustDo("GET", "/search?q=hello", ""), &result)
+	if len(result.Hits) != 1 || result.Hits[0].Post.NumOfThumb != 1 {
+		t.Errorf("search hits after a flush: got %+v", result.Hits)
+	}
+}
+
+func TestBufferedReactionsOfDeletedTargets(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeSession, newTestRedis(t))
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	commentID := field(t, alice.mustDo("POST", "/comments/"+id, `{"commentContent":"Nice"}`), "commentID")
+	alice.mustDo("PUT", "/posts/"+id+"/reactions", `{"type":"like"}`)
+	alice.mustDo("PUT", "/comments/"+commentID+"/reactions", `{"type":"like"}`)
+
+	// gone before the counts are flushed
+	ctx := context.Background()
+	if err := s.comments.DeleteComment(ctx, mustObjectID(t, commentID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.posts.DeletePost(ctx, mustObjectID(t, id)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, buffer := range map[string]*counters.Buffer{"posts": s.postsHandler.Counters(), "comments": s.commentsHandler.Counters()} {
+		if err := buffer.FlushAll(ctx); err != nil {
+			t.Errorf("flush of the %s counters: %v", name, err)
+		}
+		if err := buffer.FlushAll(ctx); err != nil {
+			t.Errorf("second flush of the %s counters: %v", name, err)
+		}
+	}
+	if pending, _ := s.postsHandler.Counters().Pending(mustObjectID(t, id)); len(pending) != 0 {
+		t.Errorf("counts of a deleted post are kept: %v", pending)
+	}
+}
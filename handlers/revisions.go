@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/diff"
+	"blogo/logging"
+	"blogo/models"
+	"blogo/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postUpdate is the body accepted by the edit endpoints. A nil title, tags
+// or content is left untouched by PATCH and cleared by PUT, while a nil
+// status or publish time is left untouched by both. The edited post is
+// checked against the rules of models.Post.
+type postUpdate struct {
+	Title     *string            `json:"postTitle"`
+	Tags      *[]string          `json:"postTags"`
+	Content   *string            `json:"postContent"`
+	Status    *models.PostStatus `json:"status"`
+	PublishAt *time.Time         `json:"publishAt"`
+}
+
+type titleDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type revisionDiff struct {
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	Title       *titleDiff  `json:"postTitle,omitempty"`
+	TagsRemoved []string    `json:"postTagsRemoved"`
+	TagsAdded   []string    `json:"postTagsAdded"`
+	Content     []diff.Line `json:"postContent"`
+}
+
+// swagger:operation PUT /posts/{id} post updatePost
+// Replace the title, tags and content of a post
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: id
+//     in: path
+//     description: ID of the post
+//     required: true
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID or body
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) UpdatePostHandler(c *gin.Context) error {
+	return handler.editPost(c, true)
+}
+
+// swagger:operation PATCH /posts/{id} post patchPost
+// Update some of the title, tags and content of a post
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: id
+//     in: path
+//     description: ID of the post
+//     required: true
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID or body
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) PatchPostHandler(c *gin.Context) error {
+	return handler.editPost(c, false)
+}
+
+func (handler *PostsHandler) editPost(c *gin.Context, replace bool) error {
+	post, err := handler.loadPost(c)
+	if err != nil {
+		return err
+	}
+	if err := authorize(c, post.Username); err != nil {
+		return err
+	}
+
+	var update postUpdate
+	if err := bindJSON(c, &update); err != nil {
+		return err
+	}
+
+	edited := post
+	if replace {
+		edited.Title, edited.Tags, edited.Content = "", nil, ""
+	}
+	if update.Title != nil {
+		edited.Title = *update.Title
+	}
+	if update.Tags != nil {
+		tags, err := handler.normalizeTags(c.Request.Context(), *update.Tags)
+		if err != nil {
+			return err
+		}
+		edited.Tags = tags
+	}
+	if update.Content != nil {
+		edited.Content = *update.Content
+	}
+	edited.Status = edited.EffectiveStatus()
+	if update.Status != nil {
+		edited.Status = *update.Status
+	}
+	if update.PublishAt != nil {
+		edited.PublishAt = update.PublishAt
+	}
+	if err := validate(edited); err != nil {
+		return err
+	}
+	if err := checkSchedule(&edited); err != nil {
+		return err
+	}
+
+	return handler.savePost(c, post, edited)
+}
+
+// swagger:operation GET /posts/{id}/revisions post listRevisions
+// List the revisions of a post, newest first. The history of a post is only
+// shown to its author and moderators.
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: id
+//     in: path
+//     description: ID of the post
+//     required: true
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID
+//   '401':
+//     description: Not signed in
+//   '403':
+//     description: Signed in user is neither the author nor a moderator
+//   '404':
+//     description: post with provided ID not found
+func (handler *PostsHandler) ListRevisionsHandler(c *gin.Context) error {
+	post, err := handler.loadHistory(c)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := handler.revisions.ListRevisions(c.Request.Context(), post.PostID)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, revisions)
+	return nil
+}
+
+// swagger:operation GET /posts/{id}/revisions/{revision} post viewRevision
+// View a single revision of a post
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post or revision ID
+//   '401':
+//     description: Not signed in
+//   '403':
+//     description: Signed in user is neither the author nor a moderator
+//   '404':
+//     description: post or revision not found
+func (handler *PostsHandler) ViewRevisionHandler(c *gin.Context) error {
+	post, err := handler.loadHistory(c)
+	if err != nil {
+		return err
+	}
+	revision, err := handler.loadRevision(c, post, c.Param("revision"))
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, revision)
+	return nil
+}
+
+// swagger:operation GET /posts/{id}/diff post diffRevisions
+// Compare two revisions of a post
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: from
+//     in: query
+//     description: ID of the older revision
+//     required: true
+//     type: string
+//   - name: to
+//     in: query
+//     description: ID of the newer revision, or "current" for the post itself
+//     required: false
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post or revision ID
+//   '401':
+//     description: Not signed in
+//   '403':
+//     description: Signed in user is neither the author nor a moderator
+//   '404':
+//     description: post or revision not found
+func (handler *PostsHandler) DiffRevisionsHandler(c *gin.Context) error {
+	post, err := handler.loadHistory(c)
+	if err != nil {
+		return err
+	}
+
+	from, err := handler.loadRevision(c, post, c.Query("from"))
+	if err != nil {
+		return err
+	}
+	to, err := handler.loadRevision(c, post, c.DefaultQuery("to", "current"))
+	if err != nil {
+		return err
+	}
+
+	result := revisionDiff{
+		From:    c.Query("from"),
+		To:      c.DefaultQuery("to", "current"),
+		Content: diff.Lines(from.Content, to.Content),
+	}
+	if from.Title != to.Title {
+		result.Title = &titleDiff{From: from.Title, To: to.Title}
+	}
+	result.TagsRemoved, result.TagsAdded = diff.Strings(from.Tags, to.Tags)
+
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+// swagger:operation POST /posts/{id}/revisions/{revision}/restore post restoreRevision
+// Restore a post to one of its revisions
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post or revision ID
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: post or revision not found
+func (handler *PostsHandler) RestoreRevisionHandler(c *gin.Context) error {
+	post, err := handler.loadPost(c)
+	if err != nil {
+		return err
+	}
+	if err := authorize(c, post.Username); err != nil {
+		return err
+	}
+	revision, err := handler.loadRevision(c, post, c.Param("revision"))
+	if err != nil {
+		return err
+	}
+
+	// tags may have been renamed or merged since the revision was taken
+	tags, err := handler.normalizeTags(c.Request.Context(), revision.Tags)
+	if err != nil {
+		return err
+	}
+
+	restored := post
+	restored.Title = revision.Title
+	restored.Tags = tags
+	restored.Content = revision.Content
+
+	return handler.savePost(c, post, restored)
+}
+
+// savePost stores edited in place of old and then records old as a
+// revision, unless its title, tags and content stayed the same. The slug
+// follows a new title, the former one redirecting to it.
+func (handler *PostsHandler) savePost(c *gin.Context, old, edited models.Post) error {
+	now := time.Now()
+	edited.LastUpdatedTime = now
+	var err error
+	if edited.Title == old.Title && old.Slug != "" {
+		err = handler.posts.UpdatePost(c.Request.Context(), edited)
+	} else {
+		err = handler.saveWithSlug(c.Request.Context(), edited, func(slug string) error {
+			edited.Slug, edited.OldSlugs = old.Slug, old.OldSlugs
+			if slug != old.Slug {
+				edited.Slug = slug
+				edited.OldSlugs = withoutSlug(old.OldSlugs, slug)
+				if old.Slug != "" {
+					edited.OldSlugs = append(edited.OldSlugs, old.Slug)
+				}
+			}
+			return handler.posts.UpdatePost(c.Request.Context(), edited)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if revised(old, edited) {
+		revision := models.Revision{
+			RevisionID:  primitive.NewObjectID(),
+			PostID:      old.PostID,
+			EditedBy:    currentUsername(c),
+			Title:       old.Title,
+			Tags:        old.Tags,
+			Content:     old.Content,
+			CreatedTime: now,
+		}
+		// the edit is saved either way, only its undo is lost
+		if err := handler.revisions.InsertRevision(c.Request.Context(), revision); err != nil {
+			logging.FromContext(c.Request.Context()).Error("Record revision failed", "post", old.PostID.Hex(), "error", err)
+		}
+	}
+
+	handler.indexPost(c.Request.Context(), edited)
+	handler.invalidatePosts(edited.PostID)
+	c.JSON(http.StatusOK, edited)
+	return nil
+}
+
+// revised reports whether the title, tags or content of a post differ
+// between old and edited.
+func revised(old, edited models.Post) bool {
+	removed, added := diff.Strings(old.Tags, edited.Tags)
+	return old.Title != edited.Title || old.Content != edited.Content || len(removed) > 0 || len(added) > 0
+}
+
+// loadPost looks up the post named by the id path parameter. Posts the
+// signed in user may not see are not found.
+func (handler *PostsHandler) loadPost(c *gin.Context) (models.Post, error) {
+	postID, err := paramID(c, "id")
+	if err != nil {
+		return models.Post{}, err
+	}
+	post, err := handler.posts.GetPost(c.Request.Context(), postID)
+	if err == nil && !canView(c, post) {
+		return models.Post{}, store.ErrNotFound
+	}
+	return post, err
+}
+
+// loadHistory is loadPost for the revision endpoints, which only the author
+// of the post and moderators may use.
+func (handler *PostsHandler) loadHistory(c *gin.Context) (models.Post, error) {
+	post, err := handler.loadPost(c)
+	if err != nil {
+		return post, err
+	}
+	if currentUsername(c) != post.Username && !canModerate(c) {
+		return models.Post{}, apierror.New(apierror.CodeForbidden, "only the author or a moderator can see the history of this post")
+	}
+	return post, nil
+}
+
+// loadRevision looks up a revision of post. The special id "current"
+// stands for the post as it is now.
+func (handler *PostsHandler) loadRevision(c *gin.Context, post models.Post, id string) (models.Revision, error) {
+	if id == "current" {
+		return models.Revision{
+			PostID:      post.PostID,
+			Title:       post.Title,
+			Tags:        post.Tags,
+			Content:     post.Content,
+			CreatedTime: post.LastUpdatedTime,
+		}, nil
+	}
+
+	revisionID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Revision{}, apierror.New(apierror.CodeBadRequest, "%q is not a valid revision id", id)
+	}
+
+	revision, err := handler.revisions.GetRevision(c.Request.Context(), revisionID)
+	if err == nil && revision.PostID != post.PostID {
+		return revision, store.ErrNotFound
+	}
+	return revision, err
+}
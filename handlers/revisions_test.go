@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"blogo/models"
+	"net/http"
+	"testing"
+)
+
+func TestRevisions(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	id := field(t, alice.mustDo("POST", "/posts", `{"username":"alice","postTitle":"Draft","postTags":["go"],"postContent":"one\ntwo"}`), "postID")
+
+	bob := s.client(t)
+	bob.signUp("bob")
+	if code, _ := bob.do("PATCH", "/posts/"+id, `{"postTitle":"Mine"}`); code != http.StatusForbidden {
+		t.Errorf("PATCH by another user: got %d, want 403", code)
+	}
+
+	var post models.Post
+	decode(t, alice.mustDo("PATCH", "/posts/"+id, `{"postContent":"one\nthree"}`), &post)
+	if post.Title != "Draft" || post.Content != "one\nthree" {
+		t.Errorf("PATCH keeps untouched fields: got %+v", post)
+	}
+	decode(t, alice.mustDo("PUT", "/posts/"+id, `{"postTitle":"Final"}`), &post)
+	if post.Title != "Final" || post.Content != "" || len(post.Tags) != 0 {
+		t.Errorf("PUT clears missing fields: got %+v", post)
+	}
+
+	// an edit that only changes the status is not a revision
+	alice.mustDo("PATCH", "/posts/"+id, `{"status":"archived"}`)
+	alice.mustDo("PATCH", "/posts/"+id, `{"postTitle":"Final","status":"published"}`)
+
+	// the history is for the author and moderators only
+	for _, path := range []string{"/revisions", "/diff?from=current"} {
+		if code, _ := bob.do("GET", "/posts/"+id+path, ""); code != http.StatusForbidden {
+			t.Errorf("GET %s by another user: got %d, want 403", path, code)
+		}
+		if code, _ := s.client(t).do("GET", "/posts/"+id+path, ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s anonymously: got %d, want 401", path, code)
+		}
+	}
+	s.setRole(t, "bob", models.RoleModerator)
+
+	var revisions []models.Revision
+	decode(t, bob.mustDo("GET", "/posts/"+id+"/revisions", ""), &revisions)
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+	first := revisions[1]
+	if first.Content != "one\ntwo" || first.EditedBy != "alice" {
+		t.Errorf("oldest revision: got %+v", first)
+	}
+
+	var d revisionDiff
+	decode(t, bob.mustDo("GET", "/posts/"+id+"/diff?from="+first.RevisionID.Hex(), ""), &d)
+	if d.Title == nil || d.Title.From != "Draft" || d.Title.To != "Final" {
+		t.Errorf("title diff: got %+v", d.Title)
+	}
+	if len(d.TagsRemoved) != 1 || d.TagsRemoved[0] != "go" {
+		t.Errorf("removed tags: got %v", d.TagsRemoved)
+	}
+
+	decode(t, alice.mustDo("POST", "/posts/"+id+"/revisions/"+first.RevisionID.Hex()+"/restore", ""), &post)
+	if post.Title != "Draft" || post.Content != "one\ntwo" {
+		t.Errorf("restored post: got %+v", post)
+	}
+	if code, _ := alice.do("GET", "/posts/"+id+"/revisions/"+id, ""); code != http.StatusNotFound {
+		t.Errorf("GET unknown revision: got %d, want 404", code)
+	}
+}
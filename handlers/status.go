@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/logging"
+	"blogo/models"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// checkSchedule makes sure that a scheduled post is due in the future and
+// clears the publish time of posts that are not scheduled.
+func checkSchedule(post *models.Post) error {
+	if post.Status != models.StatusScheduled {
+		post.PublishAt = nil
+		return nil
+	}
+	if post.PublishAt == nil || !post.PublishAt.After(time.Now()) {
+		return apierror.Invalid([]apierror.FieldError{{Field: "publishAt", Message: "must be in the future for scheduled posts"}})
+	}
+	return nil
+}
+
+// PublishScheduled publishes the scheduled posts that are due. It is meant
+// to run periodically in the background.
+func (handler *PostsHandler) PublishScheduled(ctx context.Context) error {
+	ids, err := handler.posts.PublishDuePosts(ctx, time.Now())
+	if len(ids) > 0 {
+		handler.reindexPosts(ctx, ids)
+		handler.invalidatePosts(ids...)
+		logging.FromContext(ctx).Info("Published scheduled posts", "count", len(ids))
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/store"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPostStatus(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+
+	var draft models.Post
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Draft","status":"draft"}`), &draft)
+	if draft.Status != models.StatusDraft {
+		t.Fatalf("new draft: got status %q", draft.Status)
+	}
+	id := draft.PostID.Hex()
+	alice.mustDo("POST", "/posts", `{"postTitle":"Published"}`)
+
+	alice.mustDo("GET", "/posts/"+id, "")
+	if code, _ := bob.do("GET", "/posts/"+id, ""); code != http.StatusNotFound {
+		t.Errorf("draft seen by another user: got %d, want 404", code)
+	}
+	if code, _ := bob.do("GET", "/posts/"+id+"/revisions", ""); code != http.StatusNotFound {
+		t.Errorf("revisions of a draft seen by another user: got %d, want 404", code)
+	}
+
+	var page store.PostPage
+	decode(t, bob.mustDo("GET", "/posts", ""), &page)
+	if page.Total != 1 || page.Posts[0].Title != "Published" {
+		t.Errorf("GET /posts lists drafts: got %+v", page.Posts)
+	}
+	decode(t, alice.mustDo("GET", "/posts?status=draft&author=alice", ""), &page)
+	if page.Total != 1 || page.Posts[0].Title != "Draft" {
+		t.Errorf("own drafts: got %+v", page.Posts)
+	}
+	if code, _ := bob.do("GET", "/posts?status=draft&author=alice", ""); code != http.StatusForbidden {
+		t.Errorf("drafts of another user: got %d, want 403", code)
+	}
+	if code, _ := alice.do("GET", "/posts?status=deleted", ""); code != http.StatusBadRequest {
+		t.Errorf("unknown status: got %d, want 400", code)
+	}
+
+	// archived posts can be viewed but are not listed
+	alice.mustDo("PATCH", "/posts/"+id, `{"status":"archived"}`)
+	bob.mustDo("GET", "/posts/"+id, "")
+	decode(t, bob.mustDo("GET", "/posts", ""), &page)
+	if page.Total != 1 {
+		t.Errorf("GET /posts lists archived posts: got %+v", page.Posts)
+	}
+}
+
+func TestScheduledPosts(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Late","status":"scheduled","publishAt":"`+past+`"}`); code != http.StatusBadRequest {
+		t.Errorf("scheduled in the past: got %d, want 400", code)
+	}
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Whenever","status":"scheduled"}`); code != http.StatusBadRequest {
+		t.Errorf("scheduled without publishAt: got %d, want 400", code)
+	}
+
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	var post models.Post
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"Soon","status":"scheduled","publishAt":"`+future+`"}`), &post)
+	if post.Status != models.StatusScheduled || post.PublishAt == nil {
+		t.Fatalf("scheduled post: got %+v", post)
+	}
+
+	// nothing is due yet
+	if err := s.postsHandler.PublishScheduled(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := s.client(t).do("GET", "/posts/"+post.PostID.Hex(), ""); code != http.StatusNotFound {
+		t.Errorf("scheduled post before it is due: got %d, want 404", code)
+	}
+
+	due := time.Now().Add(-time.Minute)
+	post.PublishAt = &due
+	if err := s.posts.UpdatePost(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.postsHandler.PublishScheduled(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var published models.Post
+	decode(t, s.client(t).mustDo("GET", "/posts/"+post.PostID.Hex(), ""), &published)
+	if published.Status != models.StatusPublished || published.PublishAt != nil {
+		t.Errorf("post after it is due: got %+v", published)
+	}
+	var results struct {
+		Total int64 `json:"total"`
+	}
+	decode(t, s.client(t).mustDo("GET", "/search?q=soon", ""), &results)
+	if results.Total != 1 {
+		t.Errorf("published post is not searchable: got %d results", results.Total)
+	}
+}
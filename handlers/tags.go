@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/logging"
+	"blogo/models"
+	"blogo/slug"
+	"blogo/store"
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tagSummary is a tag as listed by GET /tags.
+type tagSummary struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// relatedTag is a tag that often appears together with another one. Score
+// is the Jaccard index of the two sets of posts.
+type relatedTag struct {
+	Slug  string  `json:"slug"`
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Score float64 `json:"score"`
+}
+
+// swagger:operation GET /tags tag listTags
+// List the tags in use with their number of posts, most used first
+// ---
+// produces:
+// - application/json
+// responses:
+//  '200':
+//   description: Successful operation
+func (handler *PostsHandler) ListTagsHandler(c *gin.Context) error {
+	counts, err := handler.posts.TagCounts(c.Request.Context())
+	if err != nil {
+		return err
+	}
+	names, err := handler.tagNames(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	tags := make([]tagSummary, 0, len(counts))
+	for _, count := range counts {
+		tags = append(tags, tagSummary{Slug: count.Slug, Name: nameOr(names, count.Slug), Count: count.Count})
+	}
+	c.JSON(http.StatusOK, tags)
+	return nil
+}
+
+// swagger:operation GET /tags/{slug}/posts tag listTagPosts
+// Return one page of the posts with a tag
+// ---
+// produces:
+// - application/json
+// description: Takes the paging and sorting parameters of GET /posts.
+// responses:
+//  '200':
+//   description: Successful operation
+//  '301':
+//   description: slug is an alias, follow the redirect to the tag
+//  '400':
+//   description: Invalid query parameter or cursor
+func (handler *PostsHandler) ListTagPostsHandler(c *gin.Context) error {
+	query, err := parsePostQuery(c)
+	if err != nil {
+		return err
+	}
+
+	tag, ok, err := handler.canonicalTag(c, "posts")
+	if err != nil || !ok {
+		return err
+	}
+	query.Tag = tag
+	return handler.listPosts(c, query)
+}
+
+// swagger:operation GET /tags/{slug}/related tag relatedTags
+// List the tags that most often appear together with a tag
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: limit
+//     in: query
+//     description: number of tags, at most 100
+//     type: integer
+// responses:
+//  '200':
+//   description: Successful operation
+//  '301':
+//   description: slug is an alias, follow the redirect to the tag
+func (handler *PostsHandler) RelatedTagsHandler(c *gin.Context) error {
+	limit, err := intQuery(c, "limit", 1, 100)
+	if err != nil {
+		return err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	tag, ok, err := handler.canonicalTag(c, "related")
+	if err != nil || !ok {
+		return err
+	}
+
+	related, err := handler.posts.RelatedTags(c.Request.Context(), tag)
+	if err != nil {
+		return err
+	}
+	counts, err := handler.posts.TagCounts(c.Request.Context())
+	if err != nil {
+		return err
+	}
+	names, err := handler.tagNames(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	total := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		total[count.Slug] = count.Count
+	}
+	tags := make([]relatedTag, 0, len(related))
+	for _, r := range related {
+		union := total[tag] + total[r.Slug] - r.Count
+		tags = append(tags, relatedTag{
+			Slug:  r.Slug,
+			Name:  nameOr(names, r.Slug),
+			Count: r.Count,
+			Score: float64(r.Count) / float64(union),
+		})
+	}
+	sortRelatedTags(tags)
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	c.JSON(http.StatusOK, tags)
+	return nil
+}
+
+// swagger:operation POST /admin/tags/{slug}/rename tag renameTag
+// Rename a tag. The old slug stays as an alias of the new one.
+// ---
+// produces:
+// - application/json
+// responses:
+//  '200':
+//   description: Successful operation
+//  '400':
+//   description: Invalid name
+//  '404':
+//   description: tag not found
+//  '409':
+//   description: another tag has the new slug, merge the tags instead
+func (handler *PostsHandler) RenameTagHandler(c *gin.Context) error {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		return err
+	}
+	name := normalizeTagName(body.Name)
+	newSlug := slug.Make(name)
+	if newSlug == "" {
+		return apierror.New(apierror.CodeBadRequest, "tag name must contain letters or digits")
+	}
+
+	oldSlug := c.Param("slug")
+	tag, err := handler.tags.GetTag(c.Request.Context(), oldSlug)
+	if err != nil {
+		return err
+	}
+
+	if newSlug != oldSlug {
+		if _, err := handler.tags.GetTag(c.Request.Context(), newSlug); err == nil {
+			return apierror.New(apierror.CodeConflict, "another tag is named %s, merge the tags instead", newSlug)
+		} else if err != store.ErrNotFound {
+			return err
+		}
+		owner, err := handler.tags.ResolveTag(c.Request.Context(), newSlug)
+		if err != nil {
+			return err
+		}
+		if owner != newSlug && owner != oldSlug {
+			return apierror.New(apierror.CodeConflict, "%s is an alias of the tag %s", newSlug, owner)
+		}
+	}
+
+	// the new slug may be a former name of the tag
+	renamed := models.Tag{Slug: newSlug, Name: name, Aliases: appendAliases(nil, newSlug, tag.Aliases...)}
+	if newSlug != oldSlug {
+		renamed.Aliases = appendAliases(renamed.Aliases, newSlug, oldSlug)
+	}
+	if err := handler.replaceTags(c, renamed, []string{oldSlug}); err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, renamed)
+	return nil
+}
+
+// swagger:operation POST /admin/tags/{slug}/merge tag mergeTags
+// Merge other tags into a tag. Their slugs become aliases of the tag. An
+// alias stands for the tag it belongs to.
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: from
+//     in: body
+//     description: slugs or names of the tags to merge
+//     required: true
+//     type: array
+// responses:
+//  '200':
+//   description: Successful operation
+//  '400':
+//   description: Nothing to merge
+//  '409':
+//   description: an alias of the merged tags is also another tag or its alias
+func (handler *PostsHandler) MergeTagsHandler(c *gin.Context) error {
+	var body struct {
+		From []string `json:"from"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		return err
+	}
+
+	ctx := c.Request.Context()
+	into, err := handler.resolveTag(ctx, c.Param("slug"))
+	if err != nil {
+		return err
+	}
+	if into == "" {
+		return store.ErrNotFound
+	}
+	target, err := handler.tags.GetTag(ctx, into)
+	if err == store.ErrNotFound {
+		target = models.Tag{Slug: into, Name: into}
+	} else if err != nil {
+		return err
+	}
+
+	// an alias stands for the tag it belongs to, which brings its other
+	// aliases along
+	from := make([]string, 0, len(body.From))
+	merged := make(map[string]bool, len(body.From))
+	for _, name := range body.From {
+		source, err := handler.resolveTag(ctx, name)
+		if err != nil {
+			return err
+		}
+		if source == "" || source == into || merged[source] {
+			continue
+		}
+		tag, err := handler.tags.GetTag(ctx, source)
+		if err == nil {
+			target.Aliases = appendAliases(target.Aliases, into, tag.Aliases...)
+		} else if err != store.ErrNotFound {
+			return err
+		}
+		target.Aliases = appendAliases(target.Aliases, into, source)
+		from = append(from, source)
+		merged[source] = true
+	}
+	if len(from) == 0 {
+		return apierror.New(apierror.CodeBadRequest, "no tags to merge")
+	}
+
+	// the merged tags give up their aliases, no other tag may keep one
+	for _, alias := range target.Aliases {
+		if merged[alias] {
+			continue
+		}
+		if _, err := handler.tags.GetTag(ctx, alias); err == nil {
+			return apierror.New(apierror.CodeConflict, "%s is a tag of its own, merge it too", alias)
+		} else if err != store.ErrNotFound {
+			return err
+		}
+		owner, err := handler.tags.ResolveTag(ctx, alias)
+		if err != nil {
+			return err
+		}
+		if owner != alias && owner != into && !merged[owner] {
+			return apierror.New(apierror.CodeConflict, "%s is an alias of the tag %s, merge it too", alias, owner)
+		}
+	}
+
+	if err := handler.replaceTags(c, target, from); err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, target)
+	return nil
+}
+
+// replaceTags saves tag, drops the tags from and moves their posts to tag.
+func (handler *PostsHandler) replaceTags(c *gin.Context, tag models.Tag, from []string) error {
+	if err := handler.tags.SaveTag(c.Request.Context(), tag); err != nil {
+		return err
+	}
+	for _, source := range from {
+		if source == tag.Slug {
+			continue
+		}
+		if err := handler.tags.DeleteTag(c.Request.Context(), source); err != nil && err != store.ErrNotFound {
+			return err
+		}
+	}
+
+	ids, err := handler.posts.ReplaceTags(c.Request.Context(), from, tag.Slug, time.Now())
+	if err != nil {
+		return err
+	}
+	handler.reindexPosts(c.Request.Context(), ids)
+	handler.invalidatePosts(ids...)
+	return nil
+}
+
+// normalizeTags turns the tags of a post into the slugs of canonical tags,
+// recording tags seen for the first time in the taxonomy.
+func (handler *PostsHandler) normalizeTags(ctx context.Context, names []string) ([]string, error) {
+	slugs := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = normalizeTagName(name)
+		tag, err := handler.resolveTag(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		slugs = append(slugs, tag)
+
+		if err := handler.tags.EnsureTag(ctx, models.Tag{Slug: tag, Name: name}); err != nil {
+			return nil, err
+		}
+	}
+	return slugs, nil
+}
+
+// resolveTag returns the slug of the canonical tag named name.
+func (handler *PostsHandler) resolveTag(ctx context.Context, name string) (string, error) {
+	tag := slug.Make(name)
+	if tag == "" {
+		return "", nil
+	}
+	return handler.tags.ResolveTag(ctx, tag)
+}
+
+// canonicalTag returns the tag named by the slug path parameter. When the
+// parameter is not the canonical slug the client is redirected to the same
+// endpoint of the canonical tag and ok is false.
+func (handler *PostsHandler) canonicalTag(c *gin.Context, endpoint string) (tag string, ok bool, err error) {
+	param := c.Param("slug")
+	tag, err = handler.resolveTag(c.Request.Context(), param)
+	if err != nil {
+		return "", false, err
+	}
+	if tag == "" {
+		return "", false, store.ErrNotFound
+	}
+	if tag != param {
+		location := "/tags/" + tag + "/" + endpoint
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, location)
+		return "", false, nil
+	}
+	return tag, true, nil
+}
+
+// reindexPosts hands the current state of the posts to the search engine.
+func (handler *PostsHandler) reindexPosts(ctx context.Context, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		post, err := handler.posts.GetPost(ctx, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("Reindex post failed", "post", id.Hex(), "error", err)
+			continue
+		}
+		handler.indexPost(ctx, post)
+	}
+}
+
+// tagNames maps the slugs of the taxonomy to their display names.
+func (handler *PostsHandler) tagNames(ctx context.Context) (map[string]string, error) {
+	tags, err := handler.tags.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		names[tag.Slug] = tag.Name
+	}
+	return names, nil
+}
+
+func nameOr(names map[string]string, slug string) string {
+	if name, ok := names[slug]; ok && name != "" {
+		return name
+	}
+	return slug
+}
+
+// normalizeTagName trims a tag name and collapses its inner whitespace.
+func normalizeTagName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// appendAliases adds aliases that are neither the tag itself nor already
+// known.
+func appendAliases(aliases []string, tag string, more ...string) []string {
+	for _, alias := range more {
+		known := alias == tag
+		for _, a := range aliases {
+			known = known || a == alias
+		}
+		if !known {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+func sortRelatedTags(tags []relatedTag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Score != tags[j].Score {
+			return tags[i].Score > tags[j].Score
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/store"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// tagCounts returns the counts of GET /tags by slug.
+func tagCounts(t *testing.T, client *testClient) map[string]int64 {
+	t.Helper()
+	var tags []tagSummary
+	decode(t, client.mustDo("GET", "/tags", ""), &tags)
+	counts := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		counts[tag.Slug] = tag.Count
+	}
+	return counts
+}
+
+func TestTags(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+
+	var post models.Post
+	decode(t, alice.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["Go", " web ", "go"]}`), &post)
+	if len(post.Tags) != 2 || post.Tags[0] != "go" || post.Tags[1] != "web" {
+		t.Fatalf("tags of a new post: got %v, want [go web]", post.Tags)
+	}
+	alice.mustDo("POST", "/posts", `{"postTitle":"Two","postTags":["go","db"]}`)
+	alice.mustDo("POST", "/posts", `{"postTitle":"Three","postTags":["web"]}`)
+
+	if counts := tagCounts(t, alice); counts["go"] != 2 || counts["web"] != 2 || counts["db"] != 1 {
+		t.Errorf("GET /tags: got %v", counts)
+	}
+
+	var page store.PostPage
+	decode(t, alice.mustDo("GET", "/tags/go/posts", ""), &page)
+	if page.Total != 2 {
+		t.Errorf("GET /tags/go/posts: got %d posts, want 2", page.Total)
+	}
+
+	// db shares its only post with go, web one of two
+	var related []relatedTag
+	decode(t, alice.mustDo("GET", "/tags/go/related", ""), &related)
+	if len(related) != 2 || related[0].Slug != "db" || related[1].Slug != "web" {
+		t.Fatalf("GET /tags/go/related: got %+v", related)
+	}
+	if related[0].Score != 0.5 {
+		t.Errorf("score of db: got %v, want 0.5", related[0].Score)
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	s := newTestServer(t)
+	root := s.client(t)
+	root.signUp("root")
+	s.setRole(t, "root", models.RoleAdmin)
+	root.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["go"]}`)
+	root.mustDo("POST", "/posts", `{"postTitle":"Two","postTags":["db"]}`)
+
+	if code, _ := root.do("POST", "/admin/tags/go/rename", `{"name":"DB"}`); code != http.StatusConflict {
+		t.Errorf("rename onto another tag: got %d, want 409", code)
+	}
+	if code, _ := root.do("POST", "/admin/tags/rust/rename", `{"name":"Rust"}`); code != http.StatusNotFound {
+		t.Errorf("rename of an unknown tag: got %d, want 404", code)
+	}
+
+	var tag models.Tag
+	decode(t, root.mustDo("POST", "/admin/tags/go/rename", `{"name":"Golang"}`), &tag)
+	if tag.Slug != "golang" || len(tag.Aliases) != 1 || tag.Aliases[0] != "go" {
+		t.Fatalf("renamed tag: got %+v", tag)
+	}
+	if counts := tagCounts(t, root); counts["golang"] != 1 || counts["go"] != 0 {
+		t.Errorf("GET /tags after rename: got %v", counts)
+	}
+
+	code, _ := root.do("GET", "/tags/go/posts?limit=5", "")
+	if code != http.StatusMovedPermanently {
+		t.Errorf("GET /tags/go/posts after rename: got %d, want 301", code)
+	}
+
+	// the old name keeps working for new posts
+	var post models.Post
+	decode(t, root.mustDo("POST", "/posts", `{"postTitle":"Three","postTags":["Go"]}`), &post)
+	if len(post.Tags) != 1 || post.Tags[0] != "golang" {
+		t.Errorf("tags of a post using the old name: got %v", post.Tags)
+	}
+
+	// renaming back takes the alias over
+	decode(t, root.mustDo("POST", "/admin/tags/golang/rename", `{"name":"Go"}`), &tag)
+	if tag.Slug != "go" || len(tag.Aliases) != 1 || tag.Aliases[0] != "golang" {
+		t.Errorf("tag renamed back: got %+v", tag)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	s := newTestServer(t)
+	root := s.client(t)
+	root.signUp("root")
+	s.setRole(t, "root", models.RoleAdmin)
+	root.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["golang","go"]}`)
+	root.mustDo("POST", "/posts", `{"postTitle":"Two","postTags":["golang"]}`)
+	root.mustDo("POST", "/posts", `{"postTitle":"Three","postTags":["go-lang"]}`)
+
+	var tag models.Tag
+	decode(t, root.mustDo("POST", "/admin/tags/go/merge", `{"from":["golang","Go Lang"]}`), &tag)
+	if len(tag.Aliases) != 2 {
+		t.Errorf("merged tag: got %+v", tag)
+	}
+	if counts := tagCounts(t, root); counts["go"] != 3 || len(counts) != 1 {
+		t.Errorf("GET /tags after merge: got %v", counts)
+	}
+	if code, _ := root.do("GET", "/tags/golang/posts", ""); code != http.StatusMovedPermanently {
+		t.Errorf("GET /tags/golang/posts after merge: got %d, want 301", code)
+	}
+	if code, _ := root.do("POST", "/admin/tags/go/merge", `{"from":["go"]}`); code != http.StatusBadRequest {
+		t.Errorf("merge of a tag into itself: got %d, want 400", code)
+	}
+}
+
+func TestMergeTagsThroughAliases(t *testing.T) {
+	s := newTestServer(t)
+	root := s.client(t)
+	root.signUp("root")
+	s.setRole(t, "root", models.RoleAdmin)
+	root.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["go"]}`)
+	root.mustDo("POST", "/posts", `{"postTitle":"Two","postTags":["rust"]}`)
+	root.mustDo("POST", "/posts", `{"postTitle":"Three","postTags":["python"]}`)
+	root.mustDo("POST", "/admin/tags/go/rename", `{"name":"Golang"}`)
+
+	// merging into an alias merges into its tag
+	var tag models.Tag
+	decode(t, root.mustDo("POST", "/admin/tags/go/merge", `{"from":["rust"]}`), &tag)
+	if tag.Slug != "golang" {
+		t.Errorf("merge into an alias: got %+v", tag)
+	}
+
+	// merging an alias merges its whole tag, which gives up its aliases
+	decode(t, root.mustDo("POST", "/admin/tags/python/merge", `{"from":["go"]}`), &tag)
+	if tag.Slug != "python" || len(tag.Aliases) != 3 {
+		t.Errorf("merge of an alias: got %+v", tag)
+	}
+	if counts := tagCounts(t, root); counts["python"] != 3 || len(counts) != 1 {
+		t.Errorf("GET /tags after merge: got %v", counts)
+	}
+	tags, _ := s.tags.ListTags(context.Background())
+	if len(tags) != 1 || tags[0].Slug != "python" {
+		t.Errorf("taxonomy after merge: got %+v", tags)
+	}
+
+	// no alias may end up on two tags
+	s.tags.SaveTag(context.Background(), models.Tag{Slug: "erlang", Name: "Erlang", Aliases: []string{"beam"}})
+	s.tags.SaveTag(context.Background(), models.Tag{Slug: "beam", Name: "BEAM"})
+	if code, _ := root.do("POST", "/admin/tags/python/merge", `{"from":["erlang"]}`); code != http.StatusConflict {
+		t.Errorf("merge leaving an alias on two tags: got %d, want 409", code)
+	}
+	if _, err := s.tags.GetTag(context.Background(), "erlang"); err != nil {
+		t.Errorf("tag of a rejected merge: %v", err)
+	}
+}
+
+func TestReplaceTagsUpdatesPosts(t *testing.T) {
+	s := newTestServer(t)
+	root := s.client(t)
+	root.signUp("root")
+	s.setRole(t, "root", models.RoleAdmin)
+	var post models.Post
+	decode(t, root.mustDo("POST", "/posts", `{"postTitle":"One","postTags":["go","db"]}`), &post)
+
+	root.mustDo("POST", "/admin/tags/go/rename", `{"name":"Golang"}`)
+	var renamed models.Post
+	decode(t, root.mustDo("GET", "/posts/"+post.PostID.Hex(), ""), &renamed)
+	if !reflect.DeepEqual(renamed.Tags, []string{"db", "golang"}) {
+		t.Errorf("tags after rename: got %v", renamed.Tags)
+	}
+	// feeds and caches keyed on the update time see the change
+	if !renamed.LastUpdatedTime.After(post.LastUpdatedTime) {
+		t.Errorf("update time after rename: got %v, was %v", renamed.LastUpdatedTime, post.LastUpdatedTime)
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"blogo/apierror"
+	"blogo/counters"
+	"blogo/logging"
+	"blogo/models"
+	"blogo/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/context"
+)
+
+// TrashHandler serves the posts and comments that were deleted and purges
+// them once they have been in the trash for longer than the retention.
+type TrashHandler struct {
+	posts     *PostsHandler
+	comments  *CommentsHandler
+	retention time.Duration
+}
+
+func NewTrashHandler(posts *PostsHandler, comments *CommentsHandler, retention time.Duration) *TrashHandler {
+	return &TrashHandler{
+		posts:     posts,
+		comments:  comments,
+		retention: retention,
+	}
+}
+
+// swagger:operation GET /trash trash listTrash
+// Return one page of the posts or comments in the trash, most recently
+// deleted first. Moderators and admins see the trash of all users,
+// everybody else their own.
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: type
+//     in: query
+//     description: posts or comments, posts by default
+//     type: string
+//   - name: limit
+//     in: query
+//     description: page size, at most 100
+//     type: integer
+//   - name: cursor
+//     in: query
+//     description: nextCursor of the previous page
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid query parameter or cursor
+//   '401':
+//     description: Not signed in
+func (handler *TrashHandler) ListTrashHandler(c *gin.Context) error {
+	query := store.TrashQuery{Cursor: c.Query("cursor"), Author: currentUsername(c)}
+	if canModerate(c) {
+		query.Author = ""
+	}
+	var err error
+	if query.Limit, err = intQuery(c, "limit", 1, store.MaxPageSize); err != nil {
+		return err
+	}
+
+	switch c.DefaultQuery("type", "posts") {
+	case "posts":
+		page, err := handler.posts.posts.ListTrashedPosts(c.Request.Context(), query)
+		if err != nil {
+			return err
+		}
+		c.JSON(http.StatusOK, page)
+	case "comments":
+		page, err := handler.comments.comments.ListTrashedComments(c.Request.Context(), query)
+		if err != nil {
+			return err
+		}
+		c.JSON(http.StatusOK, page)
+	default:
+		return apierror.New(apierror.CodeBadRequest, "type must be posts or comments")
+	}
+	return nil
+}
+
+// swagger:operation POST /posts/{id}/restore trash restorePost
+// Take a post out of the trash
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: id
+//     in: path
+//     description: ID of the post
+//     required: true
+//     type: string
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid post ID
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: post with provided ID not found in the trash
+func (handler *TrashHandler) RestorePostHandler(c *gin.Context) error {
+	postID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	post, err := handler.posts.posts.GetPost(c.Request.Context(), postID)
+	if err != nil {
+		return err
+	}
+	if post.Trash == nil {
+		return apierror.New(apierror.CodeNotFound, "post is not in the trash")
+	}
+	if err := authorize(c, post.Username); err != nil {
+		return err
+	}
+
+	if err := handler.posts.posts.RestorePost(c.Request.Context(), post.PostID); err != nil {
+		return err
+	}
+	post.Trash = nil
+	handler.posts.indexPost(c.Request.Context(), post)
+	handler.posts.invalidatePosts(post.PostID)
+	c.JSON(http.StatusOK, post)
+	return nil
+}
+
+// swagger:operation POST /comments/restore/{commentid} trash restoreComment
+// Take a comment out of the trash
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful operation
+//   '400':
+//     description: Invalid comment ID
+//   '403':
+//     description: Signed in user is neither the author nor an admin
+//   '404':
+//     description: comment with provided ID not found in the trash
+func (handler *TrashHandler) RestoreCommentHandler(c *gin.Context) error {
+	commentID, err := paramID(c, "commentid")
+	if err != nil {
+		return err
+	}
+	comment, err := handler.comments.comments.GetComment(c.Request.Context(), commentID)
+	if err != nil {
+		return err
+	}
+	if comment.Trash == nil {
+		return apierror.New(apierror.CodeNotFound, "comment is not in the trash")
+	}
+	if err := authorize(c, comment.Username); err != nil {
+		return err
+	}
+
+	if err := handler.comments.comments.RestoreComment(c.Request.Context(), comment.CommentID); err != nil {
+		return err
+	}
+	comment.Trash = nil
+	c.JSON(http.StatusOK, comment)
+	return nil
+}
+
+// Purge removes the posts and comments that have been in the trash for
+// longer than the retention. A purged post takes its comments, revisions
+// and all reactions to them along. It is meant to run periodically in the
+// background.
+func (handler *TrashHandler) Purge(ctx context.Context) error {
+	query := store.TrashQuery{Before: time.Now().Add(-handler.retention), Limit: store.MaxPageSize}
+
+	purgedPosts := 0
+	for posts := query; ; {
+		page, err := handler.posts.posts.ListTrashedPosts(ctx, posts)
+		if err != nil {
+			return err
+		}
+		for _, post := range page.Posts {
+			if err := handler.purgePost(ctx, post); err != nil {
+				return err
+			}
+		}
+		purgedPosts += len(page.Posts)
+		if page.NextCursor == "" {
+			break
+		}
+		posts.Cursor = page.NextCursor
+	}
+
+	// listed after the posts, whose comments are gone by now
+	purgedComments := 0
+	for comments := query; ; {
+		page, err := handler.comments.comments.ListTrashedComments(ctx, comments)
+		if err != nil {
+			return err
+		}
+		for _, comment := range page.Comments {
+			if err := handler.purgeComment(ctx, comment); err != nil {
+				return err
+			}
+		}
+		purgedComments += len(page.Comments)
+		if page.NextCursor == "" {
+			break
+		}
+		comments.Cursor = page.NextCursor
+	}
+
+	if purgedPosts > 0 || purgedComments > 0 {
+		logging.FromContext(ctx).Info("Purged trash", "posts", purgedPosts, "comments", purgedComments)
+	}
+	return nil
+}
+
+// purgeComment removes a comment for good along with the reactions to it,
+// leaving a tombstone when it has replies.
+func (handler *TrashHandler) purgeComment(ctx context.Context, comment models.Comment) error {
+	if err := handler.comments.reactions.DeleteReactions(ctx, []primitive.ObjectID{comment.CommentID}); err != nil {
+		return err
+	}
+	if err := discardCounters(handler.comments.counters, comment.CommentID); err != nil {
+		return err
+	}
+	_, err := handler.comments.deleteComment(ctx, comment)
+	return err
+}
+
+// purgePost removes a post for good along with everything attached to it.
+func (handler *TrashHandler) purgePost(ctx context.Context, post models.Post) error {
+	commentIDs, err := handler.comments.comments.DeleteCommentsToPost(ctx, post.PostID)
+	if err != nil {
+		return err
+	}
+	if err := handler.posts.reactions.DeleteReactions(ctx, append(commentIDs, post.PostID)); err != nil {
+		return err
+	}
+	if err := discardCounters(handler.comments.counters, commentIDs...); err != nil {
+		return err
+	}
+	if err := discardCounters(handler.posts.counters, post.PostID); err != nil {
+		return err
+	}
+	if err := handler.posts.revisions.DeleteRevisions(ctx, post.PostID); err != nil {
+		return err
+	}
+	if err := handler.posts.posts.DeletePost(ctx, post.PostID); err != nil {
+		return err
+	}
+	handler.posts.invalidatePosts(post.PostID)
+	return nil
+}
+
+// discardCounters drops the reaction counts of purged targets that were not
+// flushed yet, if they are counted in Redis at all.
+func discardCounters(buffer *counters.Buffer, ids ...primitive.ObjectID) error {
+	if buffer == nil {
+		return nil
+	}
+	return buffer.Discard(ids...)
+}
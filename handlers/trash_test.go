@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/store"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// trashBody holds the first pages of the posts and comments of GET /trash.
+type trashBody struct {
+	Posts    []models.Post
+	Comments []models.Comment
+}
+
+func listTrash(t *testing.T, client *testClient) trashBody {
+	t.Helper()
+	var posts store.PostPage
+	var comments store.CommentPage
+	decode(t, client.mustDo("GET", "/trash", ""), &posts)
+	decode(t, client.mustDo("GET", "/trash?type=comments", ""), &comments)
+	return trashBody{Posts: posts.Posts, Comments: comments.Comments}
+}
+
+func TestTrash(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	bob := s.client(t)
+	bob.signUp("bob")
+	mod := s.client(t)
+	mod.signUp("mod")
+	s.setRole(t, "mod", models.RoleModerator)
+
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	commentID := field(t, bob.mustDo("POST", "/comments/"+postID, `{"commentContent":"Nice"}`), "commentID")
+
+	bob.mustDo("DELETE", "/comments/"+commentID, "")
+	var comments []models.Comment
+	decode(t, alice.mustDo("GET", "/comments/"+postID, ""), &comments)
+	if len(comments) != 0 {
+		t.Errorf("comments after trashing the only one: got %+v", comments)
+	}
+
+	alice.mustDo("DELETE", "/posts/"+postID, "")
+	if code, _ := alice.do("GET", "/posts/"+postID, ""); code != http.StatusNotFound {
+		t.Errorf("GET of a trashed post: got %d, want 404", code)
+	}
+	var page store.PostPage
+	decode(t, alice.mustDo("GET", "/posts", ""), &page)
+	if page.Total != 0 {
+		t.Errorf("GET /posts lists trashed posts: got %+v", page.Posts)
+	}
+	if code, _ := alice.do("DELETE", "/posts/"+postID, ""); code != http.StatusNotFound {
+		t.Errorf("deleting a trashed post: got %d, want 404", code)
+	}
+
+	// everybody sees their own trash, moderators everything
+	trash := listTrash(t, alice)
+	if len(trash.Posts) != 1 || len(trash.Comments) != 0 || trash.Posts[0].Trash.DeletedBy != "alice" {
+		t.Errorf("trash of alice: got %+v", trash)
+	}
+	trash = listTrash(t, bob)
+	if len(trash.Posts) != 0 || len(trash.Comments) != 1 {
+		t.Errorf("trash of bob: got %+v", trash)
+	}
+	trash = listTrash(t, mod)
+	if len(trash.Posts) != 1 || len(trash.Comments) != 1 {
+		t.Errorf("trash seen by a moderator: got %+v", trash)
+	}
+	if code, _ := s.client(t).do("GET", "/trash", ""); code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /trash: got %d, want 401", code)
+	}
+
+	if code, _ := bob.do("POST", "/posts/"+postID+"/restore", ""); code != http.StatusForbidden {
+		t.Errorf("restore of the post of another user: got %d, want 403", code)
+	}
+	alice.mustDo("POST", "/posts/"+postID+"/restore", "")
+	alice.mustDo("GET", "/posts/"+postID, "")
+	if code, _ := alice.do("POST", "/posts/"+postID+"/restore", ""); code != http.StatusNotFound {
+		t.Errorf("restore of a post outside the trash: got %d, want 404", code)
+	}
+
+	bob.mustDo("POST", "/comments/restore/"+commentID, "")
+	decode(t, alice.mustDo("GET", "/comments/"+postID, ""), &comments)
+	if len(comments) != 1 || comments[0].Content != "Nice" {
+		t.Errorf("comments after restore: got %+v", comments)
+	}
+}
+
+func TestTrashPages(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i] = field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Post"}`), "postID")
+	}
+	for _, id := range ids {
+		alice.mustDo("DELETE", "/posts/"+id, "")
+	}
+
+	// most recently deleted first
+	var got []string
+	var page store.PostPage
+	for path := "/trash?limit=2"; ; path = "/trash?limit=2&cursor=" + page.NextCursor {
+		page = store.PostPage{}
+		decode(t, alice.mustDo("GET", path, ""), &page)
+		if page.Total != 3 {
+			t.Errorf("GET %s: got total %d, want 3", path, page.Total)
+		}
+		for _, post := range page.Posts {
+			got = append(got, post.PostID.Hex())
+		}
+		if page.NextCursor == "" {
+			break
+		}
+	}
+	if want := []string{ids[2], ids[1], ids[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trashed posts: got %v, want %v", got, want)
+	}
+
+	// a cursor of GET /posts does not continue the trash
+	alice.mustDo("POST", "/posts", `{"postTitle":"Kept"}`)
+	alice.mustDo("POST", "/posts", `{"postTitle":"Kept"}`)
+	var posts store.PostPage
+	decode(t, alice.mustDo("GET", "/posts?limit=1", ""), &posts)
+	for _, query := range []string{"type=drafts", "limit=0", "cursor=nonsense", "cursor=" + posts.NextCursor} {
+		if code, _ := alice.do("GET", "/trash?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("GET /trash?%s: got %d, want 400", query, code)
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.client(t)
+	alice.signUp("alice")
+	ctx := context.Background()
+
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	alice.mustDo("PUT", "/posts/"+postID, `{"postTitle":"Hello again"}`)
+	alice.mustDo("PUT", "/posts/"+postID+"/reactions", `{"type":"like"}`)
+	commentID := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"Nice"}`), "commentID")
+	alice.mustDo("PUT", "/comments/"+commentID+"/reactions", `{"type":"like"}`)
+
+	other := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Other"}`), "postID")
+	parent := field(t, alice.mustDo("POST", "/comments/"+other, `{"commentContent":"parent"}`), "commentID")
+	alice.mustDo("POST", "/comments/"+other, `{"parentID":"`+parent+`","commentContent":"reply"}`)
+	alone := field(t, alice.mustDo("POST", "/comments/"+other, `{"commentContent":"alone"}`), "commentID")
+	alice.mustDo("PUT", "/comments/"+parent+"/reactions", `{"type":"like"}`)
+
+	alice.mustDo("DELETE", "/posts/"+postID, "")
+	alice.mustDo("DELETE", "/comments/"+parent, "")
+	alice.mustDo("DELETE", "/comments/"+alone, "")
+
+	// nothing is past the retention yet
+	if err := NewTrashHandler(s.postsHandler, s.commentsHandler, time.Hour).Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.posts.GetPost(ctx, mustObjectID(t, postID)); err != nil {
+		t.Fatalf("post purged before its retention: %v", err)
+	}
+
+	if err := NewTrashHandler(s.postsHandler, s.commentsHandler, 0).Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.posts.GetPost(ctx, mustObjectID(t, postID)); err != store.ErrNotFound {
+		t.Errorf("purged post: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.comments.GetComment(ctx, mustObjectID(t, commentID)); err != store.ErrNotFound {
+		t.Errorf("comment of a purged post: got %v, want ErrNotFound", err)
+	}
+	if revisions, _ := s.revisions.ListRevisions(ctx, mustObjectID(t, postID)); len(revisions) != 0 {
+		t.Errorf("revisions of a purged post: got %d", len(revisions))
+	}
+	for _, id := range []string{postID, commentID} {
+		if reactions, _ := s.reactions.ListReactions(ctx, mustObjectID(t, id)); len(reactions) != 0 {
+			t.Errorf("reactions to %s after purge: got %+v", id, reactions)
+		}
+	}
+
+	// a purged comment with replies stays as a tombstone
+	tombstone, err := s.comments.GetComment(ctx, mustObjectID(t, parent))
+	if err != nil || !tombstone.Deleted || tombstone.Trash != nil || tombstone.Content != "" || tombstone.NumOfThumb != 0 || len(tombstone.Reactions) != 0 {
+		t.Errorf("purged comment with replies: got %+v, %v", tombstone, err)
+	}
+	if _, err := s.comments.GetComment(ctx, mustObjectID(t, alone)); err != store.ErrNotFound {
+		t.Errorf("purged comment without replies: got %v, want ErrNotFound", err)
+	}
+	trash := listTrash(t, alice)
+	if len(trash.Posts) != 0 || len(trash.Comments) != 0 {
+		t.Errorf("trash after purge: got %+v", trash)
+	}
+}
+
+func TestPurgeDiscardsCounters(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeSession, newTestRedis(t))
+	alice := s.client(t)
+	alice.signUp("alice")
+	ctx := context.Background()
+
+	postID := field(t, alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`), "postID")
+	commentID := field(t, alice.mustDo("POST", "/comments/"+postID, `{"commentContent":"Nice"}`), "commentID")
+	alice.mustDo("PUT", "/posts/"+postID+"/reactions", `{"type":"like"}`)
+	alice.mustDo("PUT", "/comments/"+commentID+"/reactions", `{"type":"like"}`)
+	alice.mustDo("DELETE", "/posts/"+postID, "")
+
+	if err := NewTrashHandler(s.postsHandler, s.commentsHandler, 0).Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	post, comment := mustObjectID(t, postID), mustObjectID(t, commentID)
+	if pending, _ := s.postsHandler.Counters().Pending(post); len(pending[post]) != 0 {
+		t.Errorf("counts of a purged post: %v", pending)
+	}
+	if pending, _ := s.commentsHandler.Counters().Pending(comment); len(pending[comment]) != 0 {
+		t.Errorf("counts of a comment of a purged post: %v", pending)
+	}
+}
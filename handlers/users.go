@@ -1,27 +1,69 @@
 package handlers
 
 import (
+	"blogo/apierror"
+	"blogo/logging"
 	"blogo/models"
-	"context"
-	"crypto/sha256"
+	"blogo/password"
+	"blogo/store"
+	"blogo/tokens"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/xid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// AuthMode selects which credentials the API hands out and accepts.
+type AuthMode string
+
+const (
+	// AuthModeSession uses cookie sessions only.
+	AuthModeSession AuthMode = "session"
+	// AuthModeJWT uses Bearer tokens only.
+	AuthModeJWT AuthMode = "jwt"
+	// AuthModeBoth hands out both and accepts either.
+	AuthModeBoth AuthMode = "both"
+)
+
+// ParseAuthMode parses a configured mode. The empty string means
+// AuthModeSession.
+func ParseAuthMode(s string) (AuthMode, error) {
+	switch mode := AuthMode(s); mode {
+	case "":
+		return AuthModeSession, nil
+	case AuthModeSession, AuthModeJWT, AuthModeBoth:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown auth mode %q", s)
+}
+
+// UsesSessions reports whether cookie sessions are enabled.
+func (m AuthMode) UsesSessions() bool {
+	return m == AuthModeSession || m == AuthModeBoth
+}
+
+// UsesTokens reports whether Bearer tokens are enabled.
+func (m AuthMode) UsesTokens() bool {
+	return m == AuthModeJWT || m == AuthModeBoth
+}
+
 type AuthHandler struct {
-	ctx        context.Context
-	collection *mongo.Collection
+	users  store.UserStore
+	mode   AuthMode
+	tokens *tokens.Manager
 }
 
-func NewAuthHandler(ctx context.Context, collection *mongo.Collection) *AuthHandler {
+// NewAuthHandler creates the authentication handlers. tokenManager is only
+// used, and may only be nil, when mode does not use tokens.
+func NewAuthHandler(users store.UserStore, mode AuthMode, tokenManager *tokens.Manager) *AuthHandler {
 	return &AuthHandler{
-		ctx:        ctx,
-		collection: collection,
+		users:  users,
+		mode:   mode,
+		tokens: tokenManager,
 	}
 }
 
@@ -33,34 +75,48 @@ func NewAuthHandler(ctx context.Context, collection *mongo.Collection) *AuthHand
 // responses:
 //   '200':
 //     description: Successful sign in
+//   '400':
+//     description: Missing user name or password
 //   '401':
 //     description: Invalid credentials
-func (handler *AuthHandler) SignInHandler(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (handler *AuthHandler) SignInHandler(c *gin.Context) error {
+	// accounts predating the username and password rules may still sign in
+	var user struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := bindJSON(c, &user); err != nil {
+		return err
 	}
 
-	h := sha256.New()
-
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"username": user.Username,
-		"password": string(h.Sum([]byte(user.Password))),
-	})
-
-	if cur.Err() != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
-		return
+	found, err := handler.users.FindUserByUsername(c.Request.Context(), user.Username)
+	if err == store.ErrNotFound {
+		// spend the same time as for a wrong password
+		password.Hash(user.Password)
+		return apierror.New(apierror.CodeUnauthenticated, "invalid username or password")
+	} else if err != nil {
+		return err
 	}
 
-	sessionToken := xid.New().String()
-	session := sessions.Default(c)
-	session.Set("username", user.Username)
-	session.Set("token", sessionToken)
-	session.Save()
+	ok, needsRehash, err := password.Verify(user.Password, found.Password)
+	if err != nil || !ok {
+		return apierror.New(apierror.CodeUnauthenticated, "invalid username or password")
+	}
+	if needsRehash {
+		// migrate legacy and outdated hashes while we know the plaintext
+		hash, err := password.Hash(user.Password)
+		if err == nil {
+			err = handler.users.UpdateUserPassword(c.Request.Context(), found.Username, hash)
+		}
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("Rehash password failed", "username", found.Username, "error", err)
+		}
+	}
+	if found.Disabled {
+		return apierror.New(apierror.CodeForbidden, "account disabled")
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "sign in succeed", "cookie": sessionToken})
+	return handler.signIn(c, found.Username, "sign in succeed")
 }
 
 // swagger:operation POST /signout auth signOut
@@ -71,11 +127,70 @@ func (handler *AuthHandler) SignInHandler(c *gin.Context) {
 // responses:
 //   '200':
 //     description: Successful sign out
-func (handler *AuthHandler) SignOutHandler(c *gin.Context) {
-	session := sessions.Default(c)
-	session.Clear()
-	session.Save()
+func (handler *AuthHandler) SignOutHandler(c *gin.Context) error {
+	if handler.mode.UsesTokens() {
+		// revoke whatever tokens the client hands back to us
+		var body struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		c.ShouldBindJSON(&body)
+		if claims, err := handler.tokens.Parse(c.Request.Context(), bearerToken(c), tokens.Access); err == nil {
+			handler.tokens.Revoke(c.Request.Context(), claims)
+		}
+		if claims, err := handler.tokens.Parse(c.Request.Context(), body.RefreshToken, tokens.Refresh); err == nil {
+			handler.tokens.Revoke(c.Request.Context(), claims)
+		}
+	}
+	if handler.mode.UsesSessions() {
+		session := sessions.Default(c)
+		session.Clear()
+		session.Save()
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "signed out"})
+	return nil
+}
+
+// swagger:operation POST /token/refresh auth refreshToken
+// Trade a refresh token for a new access and refresh token
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Successful refresh
+//   '401':
+//     description: Invalid, expired or revoked refresh token
+//   '403':
+//     description: Account disabled
+func (handler *AuthHandler) RefreshTokenHandler(c *gin.Context) error {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		return err
+	}
+
+	claims, err := handler.tokens.Parse(c.Request.Context(), body.RefreshToken, tokens.Refresh)
+	if err != nil {
+		return err
+	}
+
+	user, err := handler.users.FindUserByUsername(c.Request.Context(), claims.Subject)
+	if err == store.ErrNotFound {
+		return tokens.ErrInvalid
+	} else if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return apierror.New(apierror.CodeForbidden, "account disabled")
+	}
+
+	pair, _, err := handler.tokens.Refresh(c.Request.Context(), body.RefreshToken)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, pair)
+	return nil
 }
 
 // swagger:operation POST /signup auth signUp
@@ -87,55 +202,159 @@ func (handler *AuthHandler) SignOutHandler(c *gin.Context) {
 //   '200':
 //     description: Successful sign up
 //   '400':
+//     description: Username or password breaks the rules, listed per field
+//   '409':
 //     description: Username is used
 //   '500':
 //     description: Server databaes error
-func (handler *AuthHandler) SignUpHandler(c *gin.Context) {
-	var newUser, user models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (handler *AuthHandler) SignUpHandler(c *gin.Context) error {
+	var newUser models.User
+	if err := bindJSON(c, &newUser); err != nil {
+		return err
 	}
 
-	err := handler.collection.FindOne(handler.ctx, bson.M{
-		"username": user.Username,
-	}).Decode(&user)
+	_, err := handler.users.FindUserByUsername(c.Request.Context(), newUser.Username)
 
-	if err != mongo.ErrNoDocuments { // username already exists
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username already exists"})
-		return
-	} else if err != mongo.ErrNoDocuments && err != nil { // unknonw database error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err == nil { // username already exists
+		return apierror.New(apierror.CodeConflict, "username already exists")
+	} else if err != store.ErrNotFound { // unknonw database error
+		return err
 	}
 
 	// do not insert plaintext
-	h := sha256.New()
-	newUser.Password = string(h.Sum([]byte(newUser.Password)))
+	newUser.Password, err = password.Hash(newUser.Password)
+	if err != nil {
+		return err
+	}
 
 	// insert the new user into database
-	_, err = handler.collection.InsertOne(handler.ctx, newUser)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	newUser.UserID = primitive.NewObjectID()
+	newUser.Role = models.DefaultRole
+	err = handler.users.InsertUser(c.Request.Context(), newUser)
+	if err == store.ErrDuplicate {
+		return apierror.New(apierror.CodeConflict, "username already exists")
+	} else if err != nil {
+		return err
+	}
+
+	return handler.signIn(c, newUser.Username, "sign up successful")
+}
+
+// signIn hands out the credentials of the configured mode to username.
+func (handler *AuthHandler) signIn(c *gin.Context, username, message string) error {
+	body := gin.H{"message": message}
+
+	if handler.mode.UsesSessions() {
+		sessionToken := xid.New().String()
+		session := sessions.Default(c)
+		session.Set("username", username)
+		session.Set("token", sessionToken)
+		session.Save()
+		body["cookie"] = sessionToken
+	}
+
+	if handler.mode.UsesTokens() {
+		pair, err := handler.tokens.Issue(username)
+		if err != nil {
+			return err
+		}
+		body["accessToken"] = pair.AccessToken
+		body["refreshToken"] = pair.RefreshToken
+		body["tokenType"] = pair.TokenType
+		body["expiresIn"] = pair.ExpiresIn
+	}
+
+	c.JSON(http.StatusOK, body)
+	return nil
+}
+
+// authenticate returns the username carried by the request, either in an
+// Authorization: Bearer header or in the session, depending on the mode.
+// It returns an empty username when the request carries neither. In
+// AuthModeBoth a session still signs the request in when its token is
+// invalid or revoked, such as a stale header left behind by a client.
+func (handler *AuthHandler) authenticate(c *gin.Context) (string, error) {
+	var tokenErr error
+	if handler.mode.UsesTokens() {
+		if raw := bearerToken(c); raw != "" {
+			claims, err := handler.tokens.Parse(c.Request.Context(), raw, tokens.Access)
+			if err == nil {
+				return claims.Subject, nil
+			}
+			if err != tokens.ErrInvalid && err != tokens.ErrRevoked {
+				return "", err
+			}
+			tokenErr = err
+		}
 	}
 
-	sessionToken := xid.New().String()
-	session := sessions.Default(c)
-	session.Set("username", user.Username)
-	session.Set("token", sessionToken)
-	session.Save()
+	if handler.mode.UsesSessions() {
+		session := sessions.Default(c)
+		username, _ := session.Get("username").(string)
+		if session.Get("token") != nil {
+			return username, nil
+		}
+	}
+	return "", tokenErr
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "sign up successful"})
+// bearerToken returns the token of an Authorization: Bearer header.
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
 }
 
+// AuthMiddileware rejects requests without a session or Bearer token and
+// loads the signed in user so that later handlers see role changes and
+// disabled accounts right away.
 func (handler *AuthHandler) AuthMiddileware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session := sessions.Default(c)
-		sessionToken := session.Get("token")
-		if sessionToken == nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "not signed in"})
-			c.Abort()
+		user, err := handler.signedInUser(c)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.Set(userKey, user)
+		c.Next()
+	}
+}
+
+// signedInUser loads the account of the credentials of the request.
+func (handler *AuthHandler) signedInUser(c *gin.Context) (models.User, error) {
+	username, err := handler.authenticate(c)
+	if err != nil {
+		return models.User{}, err
+	}
+	if username == "" {
+		return models.User{}, apierror.New(apierror.CodeUnauthenticated, "not signed in")
+	}
+
+	user, err := handler.users.FindUserByUsername(c.Request.Context(), username)
+	if err == store.ErrNotFound {
+		return user, apierror.New(apierror.CodeUnauthenticated, "not signed in")
+	} else if err != nil {
+		return user, err
+	}
+	if user.Disabled {
+		return user, apierror.New(apierror.CodeForbidden, "account disabled")
+	}
+	return user, nil
+}
+
+// OptionalAuthMiddleware loads the signed in user like AuthMiddileware but
+// lets anonymous requests and requests with bad credentials through as
+// anonymous.
+func (handler *AuthHandler) OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, err := handler.authenticate(c)
+		if err == nil && username != "" {
+			user, err := handler.users.FindUserByUsername(c.Request.Context(), username)
+			if err == nil && !user.Disabled {
+				c.Set(userKey, user)
+			}
 		}
 		c.Next()
 	}
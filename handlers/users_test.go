@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"blogo/models"
+	"blogo/tokens"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSignInMigratesLegacyHash(t *testing.T) {
+	s := newTestServer(t)
+	legacy := models.User{
+		UserID:   primitive.NewObjectID(),
+		Username: "carol",
+		Password: string(sha256.New().Sum([]byte("hunter2"))),
+	}
+	if err := s.users.InsertUser(context.Background(), legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	s.client(t).mustDo("POST", "/signin", `{"username":"carol","password":"hunter2"}`)
+
+	user, err := s.users.FindUserByUsername(context.Background(), "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.Password, "$argon2id$") {
+		t.Errorf("stored hash after sign in: got %q, want an argon2id hash", user.Password)
+	}
+	s.client(t).mustDo("POST", "/signin", `{"username":"carol","password":"hunter2"}`)
+}
+
+func TestTokens(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeJWT, nil)
+	alice := s.client(t)
+	var pair tokens.Pair
+	decode(t, alice.mustDo("POST", "/signup", `{"username":"alice","password":"passw0rd!"}`), &pair)
+	s.setRole(t, "alice", models.RoleAuthor)
+	if pair.AccessToken == "" || pair.RefreshToken == "" || len(alice.cookies) != 0 {
+		t.Fatalf("sign up in jwt mode: got %+v and cookies %v", pair, alice.cookies)
+	}
+
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Hello"}`); code != http.StatusUnauthorized {
+		t.Errorf("POST /posts without token: got %d, want 401", code)
+	}
+	alice.bearer = "garbage"
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Hello"}`); code != http.StatusUnauthorized {
+		t.Errorf("POST /posts with an invalid token: got %d, want 401", code)
+	}
+	alice.bearer = pair.AccessToken
+	alice.mustDo("POST", "/posts", `{"postTitle":"Hello"}`)
+
+	var next tokens.Pair
+	decode(t, alice.mustDo("POST", "/token/refresh", `{"refreshToken":"`+pair.RefreshToken+`"}`), &next)
+	if code, _ := alice.do("POST", "/token/refresh", `{"refreshToken":"`+pair.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("reusing a refresh token: got %d, want 401", code)
+	}
+	if code, _ := alice.do("POST", "/token/refresh", `{"refreshToken":"`+next.AccessToken+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("refreshing with an access token: got %d, want 401", code)
+	}
+
+	alice.bearer = next.AccessToken
+	alice.mustDo("POST", "/singout", `{"refreshToken":"`+next.RefreshToken+`"}`)
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Hello"}`); code != http.StatusUnauthorized {
+		t.Errorf("access token after sign out: got %d, want 401", code)
+	}
+	if code, _ := alice.do("POST", "/token/refresh", `{"refreshToken":"`+next.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("refresh token after sign out: got %d, want 401", code)
+	}
+}
+
+func TestBothModes(t *testing.T) {
+	s := newCustomTestServer(t, AuthModeBoth, nil)
+	alice := s.client(t)
+	var pair tokens.Pair
+	decode(t, alice.mustDo("POST", "/signup", `{"username":"alice","password":"passw0rd!"}`), &pair)
+	s.setRole(t, "alice", models.RoleAuthor)
+
+	// the session cookie alone is enough
+	alice.mustDo("POST", "/posts", `{"postTitle":"Session"}`)
+
+	bearer := s.client(t)
+	bearer.bearer = pair.AccessToken
+	bearer.mustDo("POST", "/posts", `{"postTitle":"Token"}`)
+
+	// a bad token does not sign out a request that has a session
+	alice.bearer = "garbage"
+	alice.mustDo("POST", "/posts", `{"postTitle":"Stale header"}`)
+	bearer.bearer = "garbage"
+	if code, _ := bearer.do("POST", "/posts", `{"postTitle":"Hello"}`); code != http.StatusUnauthorized {
+		t.Errorf("POST /posts with an invalid token and no session: got %d, want 401", code)
+	}
+}
@@ -0,0 +1,48 @@
+// Package jobs runs the periodic background work of the API server, such
+// as publishing scheduled posts.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is work that is repeated every Interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Run runs every job right away and then every interval of the job until
+// ctx is done. It returns once all runs have finished. Failed runs are
+// logged and retried at the next interval.
+func Run(ctx context.Context, jobs ...Job) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			job.loop(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (job Job) loop(ctx context.Context) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Job failed", "job", job.Name, "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
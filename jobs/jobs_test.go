@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ok, failing int32
+	done := make(chan struct{})
+	go func() {
+		Run(ctx,
+			Job{Name: "ok", Interval: time.Millisecond, Run: func(ctx context.Context) error {
+				atomic.AddInt32(&ok, 1)
+				return nil
+			}},
+			// failed runs are retried at the next interval
+			Job{Name: "failing", Interval: time.Millisecond, Run: func(ctx context.Context) error {
+				atomic.AddInt32(&failing, 1)
+				return errors.New("unreachable")
+			}},
+		)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&ok) < 3 || atomic.LoadInt32(&failing) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs ran %d and %d times", atomic.LoadInt32(&ok), atomic.LoadInt32(&failing))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestRunStartsRightAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	go Run(ctx, Job{Name: "hourly", Interval: time.Hour, Run: func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}})
+	defer cancel()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run before its first interval")
+	}
+}
@@ -0,0 +1,131 @@
+// Package logging configures the structured logger of the API and ties log
+// lines to the request they belong to.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// New creates a logger writing to w. format is text or json and level is
+// debug, info, warn or error.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, or the default logger when it has
+// none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware gives every request an ID and a logger carrying that ID, the
+// route and the user, and logs the request once it is served. The ID comes
+// from the X-Request-ID header when the client sent a usable one and is
+// echoed in the response. user names the signed in user; it is called when
+// a line is logged, so it sees users authenticated further down the chain.
+func Middleware(logger *slog.Logger, user func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		requestLogger := slog.New(&userHandler{
+			Handler: logger.With("requestID", id, "route", c.FullPath()).Handler(),
+			c:       c,
+			user:    user,
+		})
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), requestLogger))
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		requestLogger.Log(c.Request.Context(), level, "Request served",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"clientIP", c.ClientIP(),
+		)
+	}
+}
+
+// userHandler adds the user of a request to every record. Handlers format
+// the attributes given to With right away, so the user has to be added when
+// the record is handled instead.
+type userHandler struct {
+	slog.Handler
+	c    *gin.Context
+	user func(c *gin.Context) string
+}
+
+func (h *userHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.String("user", h.user(h.c)))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *userHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &userHandler{Handler: h.Handler.WithAttrs(attrs), c: h.c, user: h.user}
+}
+
+func (h *userHandler) WithGroup(name string) slog.Handler {
+	return &userHandler{Handler: h.Handler.WithGroup(name), c: h.c, user: h.user}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return r < '!' || r > '~'
+	}) < 0
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(Middleware(logger, func(c *gin.Context) string {
+		return c.GetString("user")
+	}))
+	router.GET("/posts/:id", func(c *gin.Context) {
+		// the user is known only after the middleware ran
+		c.Set("user", "alice")
+		FromContext(c.Request.Context()).Info("Viewing post")
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		echoed bool
+	}{
+		{"given", "abc-123", true},
+		{"missing", "", false},
+		{"unprintable", "abc\x01", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest("GET", "/posts/1", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.echoed && id != tt.header {
+				t.Errorf("request ID: got %q, want %q", id, tt.header)
+			}
+			if !tt.echoed && (id == "" || id == tt.header) {
+				t.Errorf("request ID: got %q, want a new one", id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+			}
+			for _, line := range lines {
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record["requestID"] != id || record["route"] != "/posts/:id" || record["user"] != "alice" {
+					t.Errorf("log line lacks the request attributes: %s", line)
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("New with format xml succeeded")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "verbose"); err == nil {
+		t.Error("New with level verbose succeeded")
+	}
+}
@@ -0,0 +1,73 @@
+// Package markdown renders the Markdown content of posts to HTML that is
+// safe to embed in a page.
+//
+// Content is parsed as GitHub flavored Markdown with footnotes. Headings get
+// ids derived from their text so that they can be linked to, and fenced code
+// blocks are highlighted with inline styles, so that clients need no
+// stylesheet. Whatever HTML the author wrote is kept only as far as the
+// sanitizer allows.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Renderer turns Markdown into sanitized HTML. It is safe for concurrent
+// use.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+}
+
+// New creates a Renderer.
+func New() *Renderer {
+	return &Renderer{
+		markdown: goldmark.New(
+			goldmark.WithExtensions(
+				extension.GFM,
+				extension.Footnote,
+				highlighting.NewHighlighting(
+					highlighting.WithStyle("github"),
+					highlighting.WithFormatOptions(chromahtml.TabWidth(4)),
+				),
+			),
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+			// raw HTML is passed on to the sanitizer instead of dropped
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+		policy: policy(),
+	}
+}
+
+// classes are the classes goldmark sets on footnotes and task lists.
+var classes = regexp.MustCompile(`^(footnotes|footnote-ref|footnote-backref|task-list-item)$`)
+
+// policy allows what users may write in Markdown plus what the renderer
+// adds: heading ids, footnote links and the colors of highlighted code.
+func policy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(classes).OnElements("a", "div", "li", "sup")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|endnotes|backlink)$`)).OnElements("a", "div")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowStyles("color", "background-color", "font-weight", "font-style", "text-decoration").OnElements("pre", "span")
+	return p
+}
+
+// Render converts source to sanitized HTML.
+func (r *Renderer) Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := r.markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return r.policy.SanitizeReader(&buf).String(), nil
+}
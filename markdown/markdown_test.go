@@ -0,0 +1,42 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	r := New()
+	tests := []struct {
+		name     string
+		source   string
+		contains []string
+		excludes []string
+	}{
+		{"heading ids", "# Hello World", []string{`<h1 id="hello-world">Hello World</h1>`}, nil},
+		{"tables", "| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}, nil},
+		{"task lists", "- [x] done", []string{`<input checked="" disabled="" type="checkbox"`}, nil},
+		{"footnotes", "Text[^1]\n\n[^1]: Note", []string{`class="footnote-ref"`, `class="footnotes"`}, nil},
+		{"highlighted code", "```go\nfunc main() {}\n```", []string{"<pre", "style=\"color:"}, nil},
+		{"scripts", "Hi <script>alert(1)</script>", []string{"Hi"}, []string{"<script", "alert(1)"}},
+		{"event handlers", `<img src="x.png" onerror="alert(1)">`, []string{`src="x.png"`}, []string{"onerror"}},
+		{"javascript links", "[click](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"foreign classes", `<div class="admin">x</div>`, nil, []string{"admin"}},
+	}
+	for _, tt := range tests {
+		html, err := r.Render(tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(html, s) {
+				t.Errorf("%s: %q lacks %q", tt.name, html, s)
+			}
+		}
+		for _, s := range tt.excludes {
+			if strings.Contains(html, s) {
+				t.Errorf("%s: %q contains %q", tt.name, html, s)
+			}
+		}
+	}
+}
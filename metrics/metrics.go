@@ -0,0 +1,166 @@
+// Package metrics collects the Prometheus metrics of the API and serves
+// them in the exposition format.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const namespace = "blogo"
+
+// Registry holds every metric of the API, plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// RequestDuration is labelled with the route template, such as
+	// /posts/:id, so that the number of series stays bounded.
+	RequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache and result, hit or miss.",
+	}, []string{"cache", "result"})
+
+	MongoDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "Latency of MongoDB commands by command, collection and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "collection", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics of Registry together with those of more
+// gatherers, such as the registry of one App.
+func Handler(more ...prometheus.Gatherer) http.Handler {
+	gatherers := append(prometheus.Gatherers{Registry}, more...)
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
+
+// Middleware times requests. Requests that match no route share the route
+// label "unmatched".
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		RequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// MongoMonitor times the commands sent through a MongoDB client that uses
+// it.
+func MongoMonitor() *event.CommandMonitor {
+	// the collection is only in the started event
+	var collections sync.Map
+	finish := func(requestID int64, command string, nanos int64, result string) {
+		collection, _ := collections.LoadAndDelete(requestID)
+		name, _ := collection.(string)
+		MongoDuration.WithLabelValues(command, name, result).Observe(time.Duration(nanos).Seconds())
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			// getMore names the cursor in its first field and the
+			// collection in another
+			field := e.CommandName
+			if field == "getMore" {
+				field = "collection"
+			}
+			if value, err := e.Command.LookupErr(field); err == nil {
+				if collection, ok := value.StringValueOK(); ok {
+					collections.Store(e.RequestID, collection)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, e.CommandName, e.DurationNanos, "ok")
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.CommandName, e.DurationNanos, "error")
+		},
+	}
+}
+
+// Sampler reads the current value of a gauge.
+type Sampler func(ctx context.Context) (float64, error)
+
+// sampledGauge is a gauge whose values are read when Prometheus scrapes.
+type sampledGauge struct {
+	name    string
+	desc    *prometheus.Desc
+	timeout time.Duration
+	samples map[string]Sampler
+}
+
+// RegisterSampled registers a gauge with registerer that calls sample on
+// every scrape, each under timeout. With a label, samples are keyed by its
+// values; without one the single sample is keyed by "". Values that fail to
+// be read are left out of the scrape. The gauges read the databases of one
+// App, so they go to its registry rather than to Registry.
+func RegisterSampled(registerer prometheus.Registerer, name, help, label string, timeout time.Duration, samples map[string]Sampler) {
+	var labels []string
+	if label != "" {
+		labels = []string{label}
+	}
+	registerer.MustRegister(&sampledGauge{
+		name:    name,
+		desc:    prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil),
+		timeout: timeout,
+		samples: samples,
+	})
+}
+
+func (g *sampledGauge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *sampledGauge) Collect(ch chan<- prometheus.Metric) {
+	for labelValue, sample := range g.samples {
+		ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+		value, err := sample(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("Sample gauge failed", "gauge", g.name, "label", labelValue, "error", err)
+			continue
+		}
+
+		var labelValues []string
+		if labelValue != "" {
+			labelValues = []string{labelValue}
+		}
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, labelValues...)
+	}
+}
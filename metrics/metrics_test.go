@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/posts/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/posts/1", "/posts/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// requests are counted by route template, not by path
+	if n := testutil.CollectAndCount(RequestDuration); n != 2 {
+		t.Errorf("got %d series, want 2", n)
+	}
+	metrics, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, family := range metrics {
+		if family.GetName() != "blogo_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var route, status string
+			for _, label := range m.GetLabel() {
+				switch label.GetName() {
+				case "route":
+					route = label.GetValue()
+				case "status":
+					status = label.GetValue()
+				}
+			}
+			counts[route+" "+status] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	if counts["/posts/:id 200"] != 2 || counts["unmatched 404"] != 1 {
+		t.Errorf("got counts %v", counts)
+	}
+}
+
+func TestRegisterSampled(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	RegisterSampled(registry, "test_documents", "Number of test documents.", "collection", time.Second, map[string]Sampler{
+		"posts": func(ctx context.Context) (float64, error) {
+			return 3, nil
+		},
+		"users": func(ctx context.Context) (float64, error) {
+			return 0, errors.New("unreachable")
+		},
+	})
+
+	// the failed sample is left out
+	expected := `
+# HELP blogo_test_documents Number of test documents.
+# TYPE blogo_test_documents gauge
+blogo_test_documents{collection="posts"} 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "blogo_test_documents"); err != nil {
+		t.Error(err)
+	}
+
+	// the gauges of another App do not collide
+	RegisterSampled(prometheus.NewRegistry(), "test_documents", "Number of test documents.", "collection", time.Second, nil)
+
+	w := httptest.NewRecorder()
+	Handler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `blogo_test_documents{collection="posts"} 3`) {
+		t.Errorf("the handler leaves out the gauges of the App:\n%s", w.Body.String())
+	}
+}
+
+func TestMongoMonitor(t *testing.T) {
+	monitor := MongoMonitor()
+	commands := []bson.D{
+		{{Key: "find", Value: "posts"}},
+		{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "comments"}},
+	}
+	for i, command := range commands {
+		raw, err := bson.Marshal(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := command[0].Key
+		monitor.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: name, RequestID: int64(i)})
+		monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+			CommandFinishedEvent: event.CommandFinishedEvent{CommandName: name, RequestID: int64(i)},
+		})
+	}
+
+	metrics, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, family := range metrics {
+		if family.GetName() != "blogo_mongo_command_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var command, collection string
+			for _, label := range m.GetLabel() {
+				switch label.GetName() {
+				case "command":
+					command = label.GetValue()
+				case "collection":
+					collection = label.GetValue()
+				}
+			}
+			counts[command+" "+collection] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	if counts["find posts"] != 1 || counts["getMore comments"] != 1 {
+		t.Errorf("got counts %v", counts)
+	}
+}
@@ -6,11 +6,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// MaxCommentDepth is how deeply replies may nest. Comments to the post
+// itself have depth 0.
+const MaxCommentDepth = 8
+
+// Comment is a comment to a post or a reply to another comment. Its content
+// is limited to 10000 characters.
 type Comment struct {
 	CommentID   primitive.ObjectID `json:"commentID" bson:"_id"`
 	Username    string             `json:"username" bson:"username"`
 	CommentToID primitive.ObjectID `json:"commentToID" bson:"commentToID"`
-	CreatedTime time.Time          `json:"commentCreatedTime" bson:"commentCreatedTime"`
-	NumOfThumb  int64              `json:"numOfThumb" bson:"numOfThumb"`
-	Content     string             `json:"commentContent" bson:"commentContent"`
+	// ParentID is the comment replied to and RootID the top level comment
+	// of the thread. Both are nil for top level comments.
+	ParentID    *primitive.ObjectID `json:"parentID,omitempty" bson:"parentID,omitempty"`
+	RootID      *primitive.ObjectID `json:"rootID,omitempty" bson:"rootID,omitempty"`
+	Depth       int                 `json:"depth" bson:"depth"`
+	CreatedTime time.Time           `json:"commentCreatedTime" bson:"commentCreatedTime"`
+	// NumOfThumb counts all reactions and Reactions counts them by type.
+	NumOfThumb int64                  `json:"numOfThumb" bson:"numOfThumb"`
+	Reactions  map[ReactionType]int64 `json:"reactions" bson:"reactions,omitempty"`
+	Content    string                 `json:"commentContent" bson:"commentContent" binding:"required,max=10000"`
+	// Deleted marks a tombstone left in place of a deleted comment that
+	// still has replies. Its author and content are cleared.
+	Deleted bool `json:"deleted" bson:"deleted"`
+	// Trash is set while the comment is in the trash.
+	Trash *Trash `json:"trash,omitempty" bson:"trash,omitempty"`
 }
@@ -6,13 +6,68 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// PostStatus is where a post is in its lifecycle.
+type PostStatus string
+
+const (
+	// StatusDraft posts are only visible to their author.
+	StatusDraft PostStatus = "draft"
+	// StatusScheduled posts are drafts that are published at their
+	// PublishAt time.
+	StatusScheduled PostStatus = "scheduled"
+	// StatusPublished posts are listed, searched and visible to everyone.
+	StatusPublished PostStatus = "published"
+	// StatusArchived posts are no longer listed or searched but can still
+	// be viewed.
+	StatusArchived PostStatus = "archived"
+)
+
+// Visible reports whether posts with status s may be viewed by users other
+// than their author.
+func (s PostStatus) Visible() bool {
+	return s == StatusPublished || s == StatusArchived
+}
+
+// Post is a blog post. The binding tags limit a post to a title of 200
+// characters, 10 tags of 50 characters each and 100000 characters of
+// content.
 type Post struct {
 	PostID          primitive.ObjectID `json:"postID" bson:"_id"`
 	Username        string             `json:"username" bson:"username"`
-	Title           string             `json:"postTitle" bson:"postTitle"`
-	Tags            []string           `json:"postTags" bson:"postTags"`
+	Title           string             `json:"postTitle" bson:"postTitle" binding:"required,max=200"`
+	Tags            []string           `json:"postTags" bson:"postTags" binding:"max=10,dive,required,max=50"`
 	CreatedTime     time.Time          `json:"postCreatedTime" bson:"postCreatedTime"`
 	LastUpdatedTime time.Time          `json:"postLastUpdatedTime" bson:"postLastUpdatedTime"`
-	NumOfThumb      int64              `json:"postNumOfThumb" bson:"postNumOfThumb"`
-	Content         string             `json:"postContent" bson:"postContent"`
+	// NumOfThumb counts all reactions and Reactions counts them by type.
+	NumOfThumb int64                  `json:"postNumOfThumb" bson:"postNumOfThumb"`
+	Reactions  map[ReactionType]int64 `json:"postReactions" bson:"postReactions,omitempty"`
+	Content    string                 `json:"postContent" bson:"postContent" binding:"max=100000"`
+	// Status is empty for posts created before statuses existed, which
+	// count as published.
+	Status PostStatus `json:"status" bson:"status,omitempty" binding:"omitempty,oneof=draft scheduled published archived"`
+	// PublishAt is when a scheduled post gets published.
+	PublishAt *time.Time `json:"publishAt,omitempty" bson:"publishAt,omitempty" binding:"required_if=Status scheduled"`
+	// Trash is set while the post is in the trash.
+	Trash *Trash `json:"trash,omitempty" bson:"trash,omitempty"`
+	// Slug names the post in permalinks. It follows the title, and the
+	// slugs the post had before redirect to the current one. Posts created
+	// before slugs existed have none until they are edited.
+	Slug     string   `json:"slug,omitempty" bson:"slug,omitempty"`
+	OldSlugs []string `json:"-" bson:"oldSlugs,omitempty"`
+	// Reaction is the reaction of the signed in user, if any. It is filled
+	// in per request and never stored.
+	Reaction ReactionType `json:"reaction,omitempty" bson:"-"`
+	Reacted  bool         `json:"reacted" bson:"-"`
+	// ContentHTML is Content rendered to sanitized HTML. It is filled in
+	// when a client asks for it and never stored.
+	ContentHTML string `json:"contentHtml,omitempty" bson:"-"`
+}
+
+// EffectiveStatus returns the status of the post. Posts created before
+// statuses existed have none stored and are treated as published.
+func (p Post) EffectiveStatus() PostStatus {
+	if p.Status == "" {
+		return StatusPublished
+	}
+	return p.Status
 }
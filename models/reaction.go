@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReactionType is the kind of reaction a user leaves on a post or comment.
+type ReactionType string
+
+const (
+	ReactionLike       ReactionType = "like"
+	ReactionInsightful ReactionType = "insightful"
+	ReactionFunny      ReactionType = "funny"
+)
+
+// ReactionTypes lists every valid reaction type.
+var ReactionTypes = []ReactionType{ReactionLike, ReactionInsightful, ReactionFunny}
+
+// Valid reports whether t is one of ReactionTypes.
+func (t ReactionType) Valid() bool {
+	for _, valid := range ReactionTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Reaction is the reaction of one user to a post or comment. A user has at
+// most one reaction per target.
+type Reaction struct {
+	ReactionID  primitive.ObjectID `json:"-" bson:"_id"`
+	TargetID    primitive.ObjectID `json:"targetID" bson:"targetID"`
+	Username    string             `json:"username" bson:"username"`
+	Type        ReactionType       `json:"type" bson:"type"`
+	CreatedTime time.Time          `json:"reactionCreatedTime" bson:"reactionCreatedTime"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revision is a snapshot of a post taken right before it was edited.
+// EditedBy is the user whose edit replaced this snapshot.
+type Revision struct {
+	RevisionID  primitive.ObjectID `json:"revisionID" bson:"_id"`
+	PostID      primitive.ObjectID `json:"postID" bson:"postID"`
+	EditedBy    string             `json:"editedBy" bson:"editedBy"`
+	Title       string             `json:"postTitle" bson:"postTitle"`
+	Tags        []string           `json:"postTags" bson:"postTags"`
+	Content     string             `json:"postContent" bson:"postContent"`
+	CreatedTime time.Time          `json:"revisionCreatedTime" bson:"revisionCreatedTime"`
+}
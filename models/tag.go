@@ -0,0 +1,10 @@
+package models
+
+// Tag is an entry of the tag taxonomy. Posts refer to tags by slug, and
+// aliases are slugs that used to name the tag or that are spelled
+// differently, such as "golang" for "go".
+type Tag struct {
+	Slug    string   `json:"slug" bson:"_id"`
+	Name    string   `json:"name" bson:"name"`
+	Aliases []string `json:"aliases" bson:"aliases"`
+}
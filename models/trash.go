@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Trash records who moved a post or comment to the trash and when. Trashed
+// content is hidden until it is restored or purged for good.
+type Trash struct {
+	DeletedBy   string    `json:"deletedBy" bson:"deletedBy"`
+	DeletedTime time.Time `json:"deletedTime" bson:"deletedTime"`
+}
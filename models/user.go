@@ -6,10 +6,52 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Role decides what a signed in user is allowed to do.
+type Role string
+
+const (
+	// RoleReader may read and comment.
+	RoleReader Role = "reader"
+	// RoleAuthor may also write posts.
+	RoleAuthor Role = "author"
+	// RoleModerator may also edit and delete content of other users.
+	RoleModerator Role = "moderator"
+	// RoleAdmin may also manage user accounts.
+	RoleAdmin Role = "admin"
+)
+
+// DefaultRole is given to users who sign up. An admin promotes those who
+// may write posts.
+const DefaultRole = RoleReader
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleReader, RoleAuthor, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// User is an account. When signing up, the username must be 3 to 32
+// letters, digits, '.', '_' or '-', and the password 8 to 128 characters
+// with at least a letter and a digit.
 type User struct {
-	Username string             `json:"username"`
-	Password string             `json:"password"`
+	Username string             `json:"username" binding:"required,min=3,max=32,username"`
+	Password string             `json:"password" binding:"required,min=8,max=128,password"`
 	UserID   primitive.ObjectID `json:"userID" bson:"_id"`
+	Role     Role               `json:"-" bson:"role"`
+	Disabled bool               `json:"-" bson:"disabled"`
+}
+
+// EffectiveRole returns the role of the user. Accounts created before
+// roles existed have none stored and are treated as authors, as they could
+// write posts back then.
+func (u User) EffectiveRole() Role {
+	if u.Role == "" {
+		return RoleAuthor
+	}
+	return u.Role
 }
 
 type UserProfile struct {
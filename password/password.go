@@ -0,0 +1,121 @@
+// Package password hashes and verifies user passwords with argon2id.
+//
+// Hashes are stored in the PHC string format
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+//
+// so that the parameters can be raised later without invalidating existing
+// hashes. Hashes written by earlier versions of Blogo, which appended an
+// unsalted SHA-256 digest of nothing to the plaintext, are still accepted by
+// Verify and reported as needing a rehash.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Params are the argon2id cost parameters.
+type Params struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the second recommended option of RFC 9106.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// ErrInvalidHash is returned when a stored hash cannot be parsed.
+var ErrInvalidHash = errors.New("password: invalid hash format")
+
+var b64 = base64.RawStdEncoding
+
+// Hash derives an encoded argon2id hash of plain using DefaultParams and a
+// fresh random salt.
+func Hash(plain string) (string, error) {
+	return HashWithParams(plain, DefaultParams)
+}
+
+// HashWithParams is like Hash but with explicit parameters.
+func HashWithParams(plain string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify reports whether plain matches the encoded hash. needsRehash is true
+// when the password is correct but the hash is a legacy one or was made with
+// parameters weaker than DefaultParams, in which case the caller should store
+// a fresh Hash of plain.
+func Verify(plain, encoded string) (ok, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		return verifyLegacy(plain, encoded), true, nil
+	}
+
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	other := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	needsRehash = p.Memory < DefaultParams.Memory ||
+		p.Iterations < DefaultParams.Iterations ||
+		p.Parallelism < DefaultParams.Parallelism
+	return true, needsRehash, nil
+}
+
+// verifyLegacy checks hashes made by sha256.New().Sum([]byte(plain)), which
+// is the plaintext followed by the digest of the empty string.
+func verifyLegacy(plain, encoded string) bool {
+	legacy := sha256.New().Sum([]byte(plain))
+	return subtle.ConstantTimeCompare(legacy, []byte(encoded)) == 1
+}
+
+func decode(encoded string) (p Params, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("password: unsupported argon2 version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+// cheap keeps the tests fast; it is weaker than DefaultParams.
+var cheap = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestVerifyLegacy(t *testing.T) {
+	legacy := string(sha256.New().Sum([]byte("hunter2")))
+
+	ok, needsRehash, err := Verify("hunter2", legacy)
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("Verify(correct, legacy) = %v, %v, %v, want true, true, nil", ok, needsRehash, err)
+	}
+	ok, _, err = Verify("hunter3", legacy)
+	if err != nil || ok {
+		t.Errorf("Verify(wrong, legacy) = %v, %v, want false, nil", ok, err)
+	}
+	ok, _, err = Verify("", legacy)
+	if err != nil || ok {
+		t.Errorf("Verify(empty, legacy) = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestVerifyArgon2id(t *testing.T) {
+	encoded, err := HashWithParams("hunter2", cheap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, needsRehash, err := Verify("hunter2", encoded)
+	if err != nil || !ok {
+		t.Errorf("Verify(correct) = %v, %v, want true, nil", ok, err)
+	}
+	if !needsRehash {
+		t.Error("Verify(correct) with weak parameters does not ask for a rehash")
+	}
+	ok, _, err = Verify("hunter3", encoded)
+	if err != nil || ok {
+		t.Errorf("Verify(wrong) = %v, %v, want false, nil", ok, err)
+	}
+
+	other, err := HashWithParams("hunter2", cheap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == encoded {
+		t.Error("two hashes of the same password are equal, salt is not random")
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=x$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	} {
+		if ok, _, err := Verify("hunter2", encoded); ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v, want an error", encoded, ok, err)
+		}
+	}
+}
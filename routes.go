@@ -0,0 +1,130 @@
+package main
+
+import (
+	"blogo/apierror"
+	"blogo/handlers"
+	"blogo/logging"
+	"blogo/metrics"
+	"blogo/models"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// routes registers the middleware and endpoints of the API.
+func (app *App) routes() *gin.Engine {
+	handle := apierror.Handler
+
+	router := gin.New()
+	// CORS headers go on every response, including errors and preflight
+	// requests answered right away
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     app.cfg.CORS.AllowOrigins,
+		AllowMethods:     []string{"POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "X-Requested-With", "Content-Length", "Content-Type", "Accept", "Authorization", "Access-Control-Request-Credentials", "Access-Control-Request-Origin", "Access-Control-Request-Methods", logging.RequestIDHeader},
+		ExposeHeaders:    []string{"Cookie", logging.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           60 * 60 * time.Hour,
+	}))
+	router.Use(metrics.Middleware())
+	router.Use(sessions.Sessions("post_api", app.sessions))
+	// panics are recovered inside the request log, which records the 500
+	router.Use(logging.Middleware(app.logger, handlers.Username), gin.Recovery())
+	router.Use(handlers.ErrorMiddleware())
+	router.Use(handlers.LimitBody(int64(app.cfg.MaxBodyBytes)))
+
+	// probes
+	router.GET("/healthz", app.health.HealthzHandler)
+	router.GET("/readyz", app.health.ReadinessHandler)
+	router.GET("/livez", app.health.LivenessHandler)
+
+	router.GET("/metrics", gin.WrapH(metrics.Handler(app.registry)))
+
+	router.NoRoute(func(c *gin.Context) {
+		apierror.Render(c, apierror.New(apierror.CodeNotFound, "no such endpoint"))
+	})
+
+	// sign in
+	router.POST("/signin", handle(app.auth.SignInHandler))
+	router.POST("/singout", handle(app.auth.SignOutHandler))
+	router.POST("/signup", handle(app.auth.SignUpHandler))
+	if app.authMode.UsesTokens() {
+		router.POST("/token/refresh", handle(app.auth.RefreshTokenHandler))
+	}
+
+	// view posts and their comments, marked with the reactions of the signed
+	// in user if any, and drafts of the signed in user
+	viewer := router.Group("/")
+	viewer.Use(app.auth.OptionalAuthMiddleware())
+	{
+		viewer.GET("/posts", handle(app.posts.ListPostsHandler))
+		viewer.GET("/posts/:id", handle(app.posts.ViewPostHandler))
+		viewer.GET("/p/:slug", handle(app.posts.ViewPostBySlugHandler))
+		viewer.GET("/posts/search/:title", handle(app.posts.SearchPostHandler))
+		viewer.GET("/random-post", handle(app.posts.GetOneRandomPost))
+		viewer.GET("/search", handle(app.posts.SearchHandler))
+		viewer.GET("/tags/:slug/posts", handle(app.posts.ListTagPostsHandler))
+		viewer.GET("/posts/:id/reactions", handle(app.posts.ListPostReactionsHandler))
+		viewer.GET("/comments/:postid", handle(app.comments.ListCommentsToPostHandler))
+	}
+
+	// view tags
+	router.GET("/tags", handle(app.posts.ListTagsHandler))
+	router.GET("/tags/:slug/related", handle(app.posts.RelatedTagsHandler))
+
+	// subscribe to the newest posts
+	router.GET("/feed.rss", handle(app.feeds.RSSHandler))
+	router.GET("/feed.atom", handle(app.feeds.AtomHandler))
+	router.GET("/feed.json", handle(app.feeds.JSONHandler))
+	router.GET("/tags/:slug/feed.rss", handle(app.feeds.RSSHandler))
+	router.GET("/tags/:slug/feed.atom", handle(app.feeds.AtomHandler))
+	router.GET("/tags/:slug/feed.json", handle(app.feeds.JSONHandler))
+	router.GET("/authors/:username/feed.rss", handle(app.feeds.RSSHandler))
+	router.GET("/authors/:username/feed.atom", handle(app.feeds.AtomHandler))
+	router.GET("/authors/:username/feed.json", handle(app.feeds.JSONHandler))
+
+	authorized := router.Group("/")
+	authorized.Use(app.auth.AuthMiddileware())
+	{
+		authorized.DELETE("/posts/:id", handle(app.posts.DeletePostHandler))
+		authorized.POST("/posts/:id/restore", handle(app.trash.RestorePostHandler))
+		authorized.PUT("/posts/:id", handle(app.posts.UpdatePostHandler))
+		authorized.PATCH("/posts/:id", handle(app.posts.PatchPostHandler))
+		authorized.GET("/posts/:id/revisions", handle(app.posts.ListRevisionsHandler))
+		authorized.GET("/posts/:id/revisions/:revision", handle(app.posts.ViewRevisionHandler))
+		authorized.GET("/posts/:id/diff", handle(app.posts.DiffRevisionsHandler))
+		authorized.POST("/posts/:id/revisions/:revision/restore", handle(app.posts.RestoreRevisionHandler))
+		authorized.POST("/posts/thumbup/:id", handle(app.posts.ThumbupPostHandler))
+		authorized.PUT("/posts/:id/reactions", handle(app.posts.ReactPostHandler))
+		authorized.DELETE("/posts/:id/reactions", handle(app.posts.UnreactPostHandler))
+		authorized.POST("/comments/:postid", handle(app.comments.CreateCommentToPostHandler))
+		authorized.POST("/comments/thumbup/:commentid", handle(app.comments.CommentThumbupHandler))
+		authorized.PUT("/comments/:commentid", handle(app.comments.UpdateCommentHandler))
+		authorized.DELETE("/comments/:commentid", handle(app.comments.DeleteCommentHandler))
+		authorized.POST("/comments/restore/:commentid", handle(app.trash.RestoreCommentHandler))
+		authorized.PUT("/comments/:commentid/reactions", handle(app.comments.ReactCommentHandler))
+		authorized.DELETE("/comments/:commentid/reactions", handle(app.comments.UnreactCommentHandler))
+		authorized.GET("/trash", handle(app.trash.ListTrashHandler))
+	}
+
+	writers := authorized.Group("/")
+	writers.Use(handlers.RequireRole(models.RoleAuthor, models.RoleModerator, models.RoleAdmin))
+	{
+		writers.POST("/posts", handle(app.posts.NewPostHandler))
+	}
+
+	admin := authorized.Group("/admin")
+	admin.Use(handlers.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handle(app.admin.ListUsersHandler))
+		admin.PUT("/users/:username/role", handle(app.admin.UpdateUserRoleHandler))
+		admin.POST("/users/:username/disable", handle(app.admin.DisableUserHandler))
+		admin.POST("/users/:username/enable", handle(app.admin.EnableUserHandler))
+		admin.POST("/tags/:slug/rename", handle(app.posts.RenameTagHandler))
+		admin.POST("/tags/:slug/merge", handle(app.posts.MergeTagsHandler))
+	}
+
+	return router
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"blogo/config"
+	"blogo/handlers"
+	"blogo/models"
+	"blogo/search"
+	"blogo/store"
+	"blogo/validation"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestApp builds an App on the in-memory stores, without MongoDB or
+// Redis, and returns it with its user store.
+func newTestApp(t *testing.T) (*App, *store.MemoryUserStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Session.Secret = "0123456789abcdef"
+	app := &App{
+		cfg:      cfg,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sessions: cookie.NewStore([]byte(cfg.Session.Secret)),
+		authMode: handlers.AuthModeSession,
+		registry: prometheus.NewRegistry(),
+	}
+
+	posts := store.NewMemoryPostStore()
+	reactions := store.NewMemoryReactionStore()
+	users := store.NewMemoryUserStore()
+	app.posts = handlers.NewPostsHandlers(posts, store.NewMemoryRevisionStore(), store.NewMemoryTagStore(), reactions, search.NewInvertedIndex(), nil)
+	app.comments = handlers.NewCommentsHandlers(store.NewMemoryCommentStore(), posts, reactions, nil)
+	app.auth = handlers.NewAuthHandler(users, app.authMode, nil)
+	app.admin = handlers.NewAdminHandler(users)
+	app.trash = handlers.NewTrashHandler(app.posts, app.comments, cfg.TrashRetention)
+	app.feeds = handlers.NewFeedHandler(app.posts, cfg.Feed.Title, cfg.Feed.URL, cfg.Feed.Size)
+	app.health = handlers.NewHealthHandler()
+	return app, users
+}
+
+// testClient sends requests to a router and keeps the cookies it sets, like
+// a browser would.
+type testClient struct {
+	t       *testing.T
+	router  http.Handler
+	cookies []*http.Cookie
+}
+
+func (client *testClient) do(method, path, body string) (int, map[string]interface{}) {
+	client.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, c := range client.cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	client.router.ServeHTTP(w, req)
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		client.cookies = cookies
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &decoded)
+	return w.Code, decoded
+}
+
+func TestRoutes(t *testing.T) {
+	app, users := newTestApp(t)
+	router := app.routes()
+	alice := &testClient{t: t, router: router}
+	bob := &testClient{t: t, router: router}
+	anonymous := &testClient{t: t, router: router}
+
+	if code, _ := anonymous.do("GET", "/healthz", ""); code != http.StatusOK {
+		t.Fatalf("GET /healthz: got %d, want 200", code)
+	}
+	if code, _ := anonymous.do("GET", "/nowhere", ""); code != http.StatusNotFound {
+		t.Fatalf("GET /nowhere: got %d, want 404", code)
+	}
+	if code, _ := anonymous.do("POST", "/posts", `{"postTitle":"Anonymous"}`); code != http.StatusUnauthorized {
+		t.Fatalf("POST /posts without session: got %d, want 401", code)
+	}
+
+	for _, client := range []*testClient{alice, bob} {
+		username := "alice"
+		if client == bob {
+			username = "bob"
+		}
+		if code, body := client.do("POST", "/signup", `{"username":"`+username+`","password":"passw0rd!"}`); code != http.StatusOK {
+			t.Fatalf("POST /signup as %s: got %d %v", username, code, body)
+		}
+	}
+	if code, _ := alice.do("POST", "/posts", `{"postTitle":"Reader"}`); code != http.StatusForbidden {
+		t.Fatalf("POST /posts as a reader: got %d, want 403", code)
+	}
+	if err := users.UpdateUserRole(context.Background(), "alice", models.RoleAuthor); err != nil {
+		t.Fatal(err)
+	}
+
+	code, post := alice.do("POST", "/posts", `{"postTitle":"Hello World","postTags":["Go"],"postContent":"First post"}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST /posts: got %d %v", code, post)
+	}
+	id, _ := post["postID"].(string)
+	if post["username"] != "alice" || post["slug"] != "hello-world" {
+		t.Fatalf("POST /posts: got %v", post)
+	}
+
+	if code, viewed := anonymous.do("GET", "/posts/"+id, ""); code != http.StatusOK || viewed["postTitle"] != "Hello World" {
+		t.Fatalf("GET /posts/%s: got %d %v", id, code, viewed)
+	}
+	if code, _ := bob.do("PUT", "/posts/"+id, `{"postTitle":"Taken over"}`); code != http.StatusForbidden {
+		t.Fatalf("PUT /posts/%s by another user: got %d, want 403", id, code)
+	}
+	if code, comment := bob.do("POST", "/comments/"+id, `{"commentContent":"Nice"}`); code != http.StatusOK || comment["username"] != "bob" {
+		t.Fatalf("POST /comments/%s: got %d %v", id, code, comment)
+	}
+
+	if code, _ := alice.do("DELETE", "/posts/"+id, ""); code != http.StatusOK {
+		t.Fatalf("DELETE /posts/%s: got %d, want 200", id, code)
+	}
+	if code, _ := anonymous.do("GET", "/posts/"+id, ""); code != http.StatusNotFound {
+		t.Fatalf("GET /posts/%s in the trash: got %d, want 404", id, code)
+	}
+	if code, _ := anonymous.do("GET", "/comments/"+id, ""); code != http.StatusNotFound {
+		t.Fatalf("GET /comments/%s of a post in the trash: got %d, want 404", id, code)
+	}
+	if code, _ := alice.do("POST", "/posts/"+id+"/restore", ""); code != http.StatusOK {
+		t.Fatalf("POST /posts/%s/restore: got %d, want 200", id, code)
+	}
+	if code, _ := anonymous.do("GET", "/posts/"+id, ""); code != http.StatusOK {
+		t.Fatalf("GET /posts/%s after restore: got %d, want 200", id, code)
+	}
+	if code, _ := anonymous.do("GET", "/p/hello-world", ""); code != http.StatusOK {
+		t.Fatalf("GET /p/hello-world: got %d, want 200", code)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	app, _ := newTestApp(t)
+	var logged bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&logged, nil))
+	router := app.routes()
+	router.GET("/panic", func(*gin.Context) { panic("boom") })
+	origin := app.cfg.CORS.AllowOrigins[0]
+
+	// errors answered by the middleware carry the CORS headers
+	req := httptest.NewRequest("POST", "/posts", strings.NewReader(strings.Repeat("x", app.cfg.MaxBodyBytes+1)))
+	req.Header.Set("Origin", origin)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || w.Header().Get("Access-Control-Allow-Origin") != origin {
+		t.Errorf("oversized body: got %d with origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/posts", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != origin {
+		t.Errorf("preflight: got %d with origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// a panic is logged as a failed request
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("panic: got %d, want 500", w.Code)
+	}
+	if !strings.Contains(logged.String(), "level=ERROR") || !strings.Contains(logged.String(), "status=500") {
+		t.Errorf("panic not logged as a 500: %s", logged.String())
+	}
+}
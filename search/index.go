@@ -0,0 +1,148 @@
+package search
+
+import (
+	"blogo/models"
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// saturation limits how much repeating a word raises the score, as in BM25.
+const saturation = 1.2
+
+// InvertedIndex is an Engine keeping an inverted index of posts in process
+// memory. It goes together with the in-memory post store.
+type InvertedIndex struct {
+	mu       sync.RWMutex
+	posts    map[primitive.ObjectID]models.Post
+	postings map[string]map[primitive.ObjectID]*frequency
+}
+
+// frequency counts how often a term occurs in each field of a post.
+type frequency struct {
+	title, tags, content float64
+}
+
+// score weighs the saturated frequencies of the fields.
+func (f *frequency) score() float64 {
+	return titleWeight*saturate(f.title) + tagsWeight*saturate(f.tags) + contentWeight*saturate(f.content)
+}
+
+func saturate(n float64) float64 {
+	return n * (saturation + 1) / (n + saturation)
+}
+
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		posts:    make(map[primitive.ObjectID]models.Post),
+		postings: make(map[string]map[primitive.ObjectID]*frequency),
+	}
+}
+
+func (idx *InvertedIndex) Index(ctx context.Context, post models.Post) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(post.PostID)
+	if post.EffectiveStatus() != models.StatusPublished || post.Trash != nil {
+		return nil
+	}
+	idx.posts[post.PostID] = post
+
+	count := func(term string) *frequency {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[primitive.ObjectID]*frequency)
+		}
+		f := idx.postings[term][post.PostID]
+		if f == nil {
+			f = &frequency{}
+			idx.postings[term][post.PostID] = f
+		}
+		return f
+	}
+	for _, term := range terms(post.Title) {
+		count(term).title++
+	}
+	for _, tag := range post.Tags {
+		for _, term := range terms(tag) {
+			count(term).tags++
+		}
+	}
+	for _, term := range terms(post.Content) {
+		count(term).content++
+	}
+	return nil
+}
+
+func (idx *InvertedIndex) Remove(ctx context.Context, id primitive.ObjectID) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	return nil
+}
+
+func (idx *InvertedIndex) remove(id primitive.ObjectID) {
+	if _, ok := idx.posts[id]; !ok {
+		return
+	}
+	delete(idx.posts, id)
+	for term, posting := range idx.postings {
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+}
+
+func (idx *InvertedIndex) Search(ctx context.Context, q Query) (Result, error) {
+	q = q.normalize()
+
+	idx.mu.RLock()
+	scores := make(map[primitive.ObjectID]float64)
+	n := float64(len(idx.posts))
+	for term := range termSet(q.Text) {
+		posting := idx.postings[term]
+		idf := math.Log(1 + n/float64(len(posting)+1))
+		for id, f := range posting {
+			scores[id] += idf * f.score()
+		}
+	}
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		post := idx.posts[id]
+		if q.Author != "" && post.Username != q.Author {
+			continue
+		}
+		if q.Tag != "" && !hasTag(post, q.Tag) {
+			continue
+		}
+		hits = append(hits, Hit{Post: post, Score: score})
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Post.PostID.Hex() > hits[j].Post.PostID.Hex()
+	})
+
+	result := Result{Hits: make([]Hit, 0, q.Limit), Total: int64(len(hits))}
+	for i := q.Offset; i < len(hits) && i < q.Offset+q.Limit; i++ {
+		result.Hits = append(result.Hits, newHit(hits[i].Post, hits[i].Score, q.Text))
+	}
+	return result, nil
+}
+
+func hasTag(post models.Post, tag string) bool {
+	for _, t := range post.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"blogo/models"
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newPost(author, title string, tags []string, content string) models.Post {
+	return models.Post{
+		PostID:   primitive.NewObjectID(),
+		Username: author,
+		Title:    title,
+		Tags:     tags,
+		Content:  content,
+	}
+}
+
+// titles returns the titles of the hits, best first.
+func titles(result Result) []string {
+	titles := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		titles = append(titles, hit.Post.Title)
+	}
+	return titles
+}
+
+func TestInvertedIndexRanking(t *testing.T) {
+	ctx := context.Background()
+	idx := NewInvertedIndex()
+	idx.Index(ctx, newPost("alice", "Cooking pasta", nil, "Boil water and add the gopher."))
+	idx.Index(ctx, newPost("alice", "Go concurrency", []string{"gopher"}, "Channels and goroutines."))
+	idx.Index(ctx, newPost("bob", "Gopher", nil, "All about the gopher."))
+
+	result, err := idx.Search(ctx, Query{Text: "Gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := titles(result)
+	want := []string{"Gopher", "Go concurrency", "Cooking pasta"}
+	if len(got) != len(want) || result.Total != 3 {
+		t.Fatalf("Search(gopher) = %v (total %d), want %v", got, result.Total, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Search(gopher) = %v, want title over tags over content: %v", got, want)
+		}
+	}
+
+	result, _ = idx.Search(ctx, Query{Text: "gopher", Author: "alice", Tag: "gopher"})
+	if got := titles(result); len(got) != 1 || got[0] != "Go concurrency" {
+		t.Errorf("Search with author and tag = %v, want [Go concurrency]", got)
+	}
+
+	result, _ = idx.Search(ctx, Query{Text: "gopher", Limit: 1, Offset: 1})
+	if got := titles(result); len(got) != 1 || got[0] != "Go concurrency" || result.Total != 3 {
+		t.Errorf("second page of one = %v (total %d), want [Go concurrency] of 3", got, result.Total)
+	}
+
+	result, _ = idx.Search(ctx, Query{Text: "the and"})
+	if result.Total != 0 {
+		t.Errorf("Search(stopwords) found %v", titles(result))
+	}
+}
+
+func TestInvertedIndexUpdate(t *testing.T) {
+	ctx := context.Background()
+	idx := NewInvertedIndex()
+	post := newPost("alice", "Old title", nil, "")
+	idx.Index(ctx, post)
+
+	post.Title = "New title"
+	idx.Index(ctx, post)
+	if result, _ := idx.Search(ctx, Query{Text: "old"}); result.Total != 0 {
+		t.Errorf("Search(old) after an edit found %v", titles(result))
+	}
+	if result, _ := idx.Search(ctx, Query{Text: "new"}); result.Total != 1 {
+		t.Errorf("Search(new) after an edit found %v", titles(result))
+	}
+
+	idx.Remove(ctx, post.PostID)
+	if result, _ := idx.Search(ctx, Query{Text: "title"}); result.Total != 0 {
+		t.Errorf("Search after Remove found %v", titles(result))
+	}
+	if len(idx.postings) != 0 {
+		t.Errorf("Remove left %d postings behind", len(idx.postings))
+	}
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoEngine is an Engine backed by a text index on the posts collection.
+// MongoDB maintains the index itself, so Index and Remove do nothing.
+type MongoEngine struct {
+	collection *mongo.Collection
+}
+
+func NewMongoEngine(collection *mongo.Collection) *MongoEngine {
+	return &MongoEngine{collection: collection}
+}
+
+// EnsureIndex creates the text index if it does not exist yet.
+func (e *MongoEngine) EnsureIndex(ctx context.Context) error {
+	_, err := e.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "postTitle", Value: "text"},
+			{Key: "postTags", Value: "text"},
+			{Key: "postContent", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("post_text").
+			SetWeights(bson.M{
+				"postTitle":   titleWeight,
+				"postTags":    tagsWeight,
+				"postContent": contentWeight,
+			}),
+	})
+	return err
+}
+
+func (e *MongoEngine) Search(ctx context.Context, q Query) (Result, error) {
+	q = q.normalize()
+
+	// posts without a status predate statuses and count as published
+	filter := bson.M{
+		"$text":  bson.M{"$search": q.Text},
+		"status": bson.M{"$in": bson.A{models.StatusPublished, nil}},
+		"trash":  bson.M{"$exists": false},
+	}
+	if q.Tag != "" {
+		filter["postTags"] = q.Tag
+	}
+	if q.Author != "" {
+		filter["username"] = q.Author
+	}
+
+	total, err := e.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return Result{}, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(q.Offset)).
+		SetLimit(int64(q.Limit))
+	cur, err := e.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	var scored []struct {
+		models.Post `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err := cur.All(ctx, &scored); err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Hits: make([]Hit, 0, len(scored)), Total: total}
+	for _, s := range scored {
+		result.Hits = append(result.Hits, newHit(s.Post, s.Score, q.Text))
+	}
+	return result, nil
+}
+
+func (e *MongoEngine) Index(ctx context.Context, post models.Post) error {
+	return nil
+}
+
+func (e *MongoEngine) Remove(ctx context.Context, id primitive.ObjectID) error {
+	return nil
+}
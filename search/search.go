@@ -0,0 +1,86 @@
+// Package search implements full-text search over posts, either with a
+// MongoDB text index or with an in-process inverted index.
+package search
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field weights shared by both engines so that they rank alike.
+const (
+	titleWeight   = 10
+	tagsWeight    = 5
+	contentWeight = 1
+)
+
+// Query is a full-text query. Posts match when they contain any of the
+// words of Text; Tag and Author narrow the matches down further.
+type Query struct {
+	Text   string
+	Tag    string
+	Author string
+	Limit  int
+	Offset int
+}
+
+// Hit is a matching post with its relevance and highlighted snippets.
+type Hit struct {
+	Post  models.Post `json:"post"`
+	Score float64     `json:"score"`
+	// Highlights holds HTML escaped snippets of the title and content with
+	// the matched words wrapped in <mark> tags.
+	Highlights Highlights `json:"highlights"`
+}
+
+type Highlights struct {
+	Title   string `json:"postTitle"`
+	Content string `json:"postContent"`
+}
+
+// Result is one page of hits, best first.
+type Result struct {
+	Hits  []Hit `json:"hits"`
+	Total int64 `json:"total"`
+}
+
+// Engine searches published posts. Engines that do not index by themselves
+// are kept up to date through Index and Remove.
+type Engine interface {
+	Search(ctx context.Context, q Query) (Result, error)
+	Index(ctx context.Context, post models.Post) error
+	Remove(ctx context.Context, id primitive.ObjectID) error
+}
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+func (q Query) normalize() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return q
+}
+
+// newHit highlights post for the words of text.
+func newHit(post models.Post, score float64, text string) Hit {
+	terms := termSet(text)
+	return Hit{
+		Post:  post,
+		Score: score,
+		Highlights: Highlights{
+			Title:   highlight(post.Title, terms, 0),
+			Content: highlight(post.Content, terms, snippetLength),
+		},
+	}
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetLength is the approximate number of bytes of content shown around
+// the first match.
+const snippetLength = 160
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// span is a word of a text, located by byte offsets.
+type span struct {
+	start, end int
+	term       string
+}
+
+// words splits s into lower cased words, keeping their positions.
+func words(s string) []span {
+	spans := make([]span, 0)
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			spans = append(spans, span{start, i, strings.ToLower(s[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(s), strings.ToLower(s[start:])})
+	}
+	return spans
+}
+
+// terms returns the searchable words of s.
+func terms(s string) []string {
+	result := make([]string, 0)
+	for _, w := range words(s) {
+		if !stopwords[w.term] {
+			result = append(result, w.term)
+		}
+	}
+	return result
+}
+
+func termSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, term := range terms(s) {
+		set[term] = true
+	}
+	return set
+}
+
+// highlight escapes s for HTML and wraps the words found in matches in
+// <mark> tags. When max is positive only about max bytes around the first
+// match are kept.
+func highlight(s string, matches map[string]bool, max int) string {
+	spans := words(s)
+	from, to := 0, len(s)
+	if max > 0 && len(s) > max {
+		first := 0
+		for _, w := range spans {
+			if matches[w.term] {
+				first = w.start
+				break
+			}
+		}
+		from = first - max/4
+		if from < 0 {
+			from = 0
+		}
+		to = from + max
+		if to > len(s) {
+			to = len(s)
+		}
+		from, to = boundary(s, spans, from), boundary(s, spans, to)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, w := range spans {
+		if w.start < from || w.end > to || !matches[w.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(s[pos:w.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(s[w.start:w.end]))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(s[pos:to]))
+	if to < len(s) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// boundary moves offset back to the start of the word or rune it falls
+// into so that the snippet never cuts either in half.
+func boundary(s string, spans []span, offset int) int {
+	for _, w := range spans {
+		if w.start < offset && offset < w.end {
+			return w.start
+		}
+	}
+	for offset > 0 && offset < len(s) && !utf8.RuneStart(s[offset]) {
+		offset--
+	}
+	return offset
+}
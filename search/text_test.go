@@ -0,0 +1,33 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	matches := termSet("go")
+	if got := highlight("Let's <Go> go!", matches, 0); got != "Let&#39;s &lt;<mark>Go</mark>&gt; <mark>go</mark>!" {
+		t.Errorf("highlight = %q", got)
+	}
+	if got := highlight("gopher", matches, 0); got != "gopher" {
+		t.Errorf("highlight marks part of a word: %q", got)
+	}
+
+	long := strings.Repeat("word ", 100) + "go " + strings.Repeat("more ", 100)
+	got := highlight(long, matches, 80)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "<mark>go</mark>") {
+		t.Errorf("snippet = %q", got)
+	}
+	if len(got) > 120 {
+		t.Errorf("snippet is %d bytes long", len(got))
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := terms("The Quick-brown fox, and 42 Füße")
+	want := []string{"quick", "brown", "fox", "42", "füße"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("terms = %v, want %v", got, want)
+	}
+}
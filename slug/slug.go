@@ -0,0 +1,110 @@
+// Package slug turns arbitrary text into lower case, URL friendly
+// identifiers such as "hello-world".
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// special spells out letters that do not decompose into a base letter and
+// combining marks.
+var special = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// cyrillic transliterates Russian, Ukrainian, Belarusian, Serbian and
+// Macedonian letters. The hard and soft signs are dropped.
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+	'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+// greek transliterates the Greek alphabet. Accented letters decompose into
+// these.
+var greek = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// spell returns the Latin spelling of the lower case letter r, if one of
+// the tables has it.
+func spell(r rune) (string, bool) {
+	for _, table := range []map[rune]string{special, cyrillic, greek} {
+		if spelled, ok := table[r]; ok {
+			return spelled, true
+		}
+	}
+	return "", false
+}
+
+// Make folds s to lower case, transliterates Cyrillic and Greek, strips
+// diacritics and joins the remaining runs of letters and digits with
+// dashes. It returns the empty string when s has no letters or digits.
+func Make(s string) string {
+	var b strings.Builder
+	dash := false
+	write := func(spelled string) {
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(spelled)
+		dash = false
+	}
+
+	for _, r := range norm.NFC.String(s) {
+		// letters such as й are looked up before they lose their marks
+		if spelled, ok := spell(unicode.ToLower(r)); ok {
+			if spelled != "" {
+				write(spelled)
+			}
+			continue
+		}
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			if spelled, ok := spell(d); ok {
+				if spelled != "" {
+					write(spelled)
+				}
+				continue
+			}
+			if unicode.IsLetter(d) || unicode.IsDigit(d) {
+				write(string(d))
+				continue
+			}
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// Limit shortens the slug s to at most n runes. It cuts at the last dash
+// that keeps the result non-empty, so that words stay whole.
+func Limit(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if runes[n] == '-' {
+		return string(runes[:n])
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndexByte(cut, '-'); i > 0 {
+		return cut[:i]
+	}
+	return strings.TrimSuffix(cut, "-")
+}
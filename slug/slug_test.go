@@ -0,0 +1,46 @@
+package slug
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Crème Brûlée  ", "creme-brulee"},
+		{"Straße & Œuvre", "strasse-oeuvre"},
+		{"C++ 2024", "c-2024"},
+		{"Привет, мир", "privet-mir"},
+		{"Київ", "kiyiv"},
+		{"Ελληνικά", "ellinika"},
+		{"日本語 text", "日本語-text"},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := Make(test.in); got != test.want {
+			t.Errorf("Make(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"hello-world", 20, "hello-world"},
+		{"hello-world", 11, "hello-world"},
+		{"hello-world", 8, "hello"},
+		{"hello-world", 5, "hello"},
+		{"a-b-c", 3, "a-b"},
+		{"helloworld", 4, "hell"},
+		{"привет-мир", 8, "привет"},
+	}
+	for _, test := range tests {
+		if got := Limit(test.in, test.n); got != test.want {
+			t.Errorf("Limit(%q, %d) = %q, want %q", test.in, test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryCommentStore is a CommentStore that keeps comments in process
+// memory. It is meant for tests and local development.
+type MemoryCommentStore struct {
+	mu       sync.RWMutex
+	comments []models.Comment
+}
+
+func NewMemoryCommentStore() *MemoryCommentStore {
+	return &MemoryCommentStore{}
+}
+
+func (s *MemoryCommentStore) ListCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := make([]models.Comment, 0)
+	for _, comment := range s.comments {
+		if comment.CommentToID == postID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (s *MemoryCommentStore) GetComment(ctx context.Context, id primitive.ObjectID) (models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return models.Comment{}, ErrNotFound
+	}
+	return s.comments[i], nil
+}
+
+func (s *MemoryCommentStore) InsertComment(ctx context.Context, comment models.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexOf(comment.CommentID) >= 0 {
+		return ErrDuplicate
+	}
+	s.comments = append(s.comments, comment)
+	return nil
+}
+
+func (s *MemoryCommentStore) IncrementCommentReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.comments[i].Reactions = addReactions(s.comments[i].Reactions, deltas)
+	s.comments[i].NumOfThumb += sumReactions(deltas)
+	return nil
+}
+
+func (s *MemoryCommentStore) UpdateCommentContent(ctx context.Context, id primitive.ObjectID, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.comments[i].Content = content
+	return nil
+}
+
+func (s *MemoryCommentStore) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.comments = append(s.comments[:i], s.comments[i+1:]...)
+	return nil
+}
+
+func (s *MemoryCommentStore) ListCommentThreads(ctx context.Context, postID primitive.ObjectID, limit, offset int) (CommentThreads, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// threads with a reply outside the trash
+	answered := make(map[primitive.ObjectID]bool)
+	for _, comment := range s.comments {
+		if comment.CommentToID == postID && comment.RootID != nil && comment.Trash == nil {
+			answered[*comment.RootID] = true
+		}
+	}
+
+	var threads CommentThreads
+	roots := make([]models.Comment, 0)
+	for _, comment := range s.comments {
+		if comment.CommentToID != postID || comment.ParentID != nil {
+			continue
+		}
+		if comment.Trash == nil && !comment.Deleted || answered[comment.CommentID] {
+			roots = append(roots, comment)
+		}
+	}
+	sortComments(roots)
+	threads.Total = int64(len(roots))
+
+	if offset > len(roots) {
+		offset = len(roots)
+	}
+	if offset+limit < len(roots) {
+		roots = roots[:offset+limit]
+	}
+	threads.Roots = roots[offset:]
+
+	page := make(map[primitive.ObjectID]bool, len(threads.Roots))
+	for _, root := range threads.Roots {
+		page[root.CommentID] = true
+	}
+	threads.Replies = make([]models.Comment, 0)
+	for _, comment := range s.comments {
+		if comment.RootID != nil && page[*comment.RootID] {
+			threads.Replies = append(threads.Replies, comment)
+		}
+	}
+	sortComments(threads.Replies)
+	return threads, nil
+}
+
+func (s *MemoryCommentStore) CountReplies(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, comment := range s.comments {
+		if comment.ParentID != nil && *comment.ParentID == id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryCommentStore) TombstoneComment(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.comments[i].Deleted = true
+	s.comments[i].Username = ""
+	s.comments[i].Content = ""
+	s.comments[i].NumOfThumb = 0
+	s.comments[i].Reactions = nil
+	s.comments[i].Trash = nil
+	return nil
+}
+
+func (s *MemoryCommentStore) TrashComment(ctx context.Context, id primitive.ObjectID, trash models.Trash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || s.comments[i].Trash != nil {
+		return ErrNotFound
+	}
+	s.comments[i].Trash = &trash
+	return nil
+}
+
+func (s *MemoryCommentStore) RestoreComment(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || s.comments[i].Trash == nil {
+		return ErrNotFound
+	}
+	s.comments[i].Trash = nil
+	return nil
+}
+
+func (s *MemoryCommentStore) ListTrashedComments(ctx context.Context, q TrashQuery) (CommentPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, sortTrash)
+	if err != nil {
+		return CommentPage{}, err
+	}
+
+	s.mu.RLock()
+	matched := make([]models.Comment, 0)
+	for _, comment := range s.comments {
+		if q.matches(comment.Trash, comment.Username) {
+			matched = append(matched, comment)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return trashCursor(matched[i].Trash, matched[i].CommentID).follows(matched[j].Trash.DeletedTime.UnixNano(), matched[j].CommentID)
+	})
+
+	comments := make([]models.Comment, 0, q.Limit+1)
+	for _, comment := range matched {
+		if after != nil && !after.follows(comment.Trash.DeletedTime.UnixNano(), comment.CommentID) {
+			continue
+		}
+		comments = append(comments, comment)
+		if len(comments) > q.Limit {
+			break
+		}
+	}
+	return newTrashedCommentPage(comments, q, int64(len(matched))), nil
+}
+
+func (s *MemoryCommentStore) DeleteCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]primitive.ObjectID, 0)
+	kept := make([]models.Comment, 0, len(s.comments))
+	for _, comment := range s.comments {
+		if comment.CommentToID == postID {
+			ids = append(ids, comment.CommentID)
+			continue
+		}
+		kept = append(kept, comment)
+	}
+	s.comments = kept
+	return ids, nil
+}
+
+// sortComments orders comments like the MongoDB store does, oldest first.
+func sortComments(comments []models.Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		if !a.CreatedTime.Equal(b.CreatedTime) {
+			return a.CreatedTime.Before(b.CreatedTime)
+		}
+		return a.CommentID.Hex() < b.CommentID.Hex()
+	})
+}
+
+func (s *MemoryCommentStore) indexOf(id primitive.ObjectID) int {
+	for i := range s.comments {
+		if s.comments[i].CommentID == id {
+			return i
+		}
+	}
+	return -1
+}
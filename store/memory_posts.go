@@ -0,0 +1,367 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryPostStore is a PostStore that keeps posts in process memory. It is
+// meant for tests and local development.
+type MemoryPostStore struct {
+	mu    sync.RWMutex
+	posts []models.Post
+}
+
+func NewMemoryPostStore() *MemoryPostStore {
+	return &MemoryPostStore{}
+}
+
+func (s *MemoryPostStore) ListPosts(ctx context.Context, q PostQuery) (PostPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	s.mu.RLock()
+	matched := make([]models.Post, 0)
+	for _, post := range s.posts {
+		if q.matches(post) {
+			matched = append(matched, post)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.Sort.less(matched[j], matched[i])
+	})
+
+	posts := make([]models.Post, 0, q.Limit+1)
+	for _, post := range matched {
+		if after != nil && !q.Sort.before(*after, post) {
+			continue
+		}
+		posts = append(posts, post)
+		if len(posts) > q.Limit {
+			break
+		}
+	}
+	return newPostPage(posts, q, int64(len(matched))), nil
+}
+
+func (s *MemoryPostStore) GetPost(ctx context.Context, id primitive.ObjectID) (models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return models.Post{}, ErrNotFound
+	}
+	return s.posts[i], nil
+}
+
+func (s *MemoryPostStore) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, post := range s.posts {
+		if post.Slug == slug {
+			return post, nil
+		}
+	}
+	for _, post := range s.posts {
+		if containsTag(post.OldSlugs, slug) {
+			return post, nil
+		}
+	}
+	return models.Post{}, ErrNotFound
+}
+
+func (s *MemoryPostStore) ReleaseSlug(ctx context.Context, slug string, owner primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, post := range s.posts {
+		if post.PostID != owner && containsTag(post.OldSlugs, slug) {
+			s.posts[i].OldSlugs = withoutSlug(post.OldSlugs, slug)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryPostStore) SamplePosts(ctx context.Context, n int) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sample := make([]models.Post, 0, n)
+	for _, i := range rand.Perm(len(s.posts)) {
+		if len(sample) == n {
+			break
+		}
+		if s.posts[i].EffectiveStatus() == models.StatusPublished && s.posts[i].Trash == nil {
+			sample = append(sample, s.posts[i])
+		}
+	}
+	return sample, nil
+}
+
+func (s *MemoryPostStore) InsertPost(ctx context.Context, post models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexOf(post.PostID) >= 0 || s.slugTaken(post) {
+		return ErrDuplicate
+	}
+	s.posts = append(s.posts, post)
+	return nil
+}
+
+func (s *MemoryPostStore) DeletePost(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.posts = append(s.posts[:i], s.posts[i+1:]...)
+	return nil
+}
+
+func (s *MemoryPostStore) IncrementPostReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.posts[i].Reactions = addReactions(s.posts[i].Reactions, deltas)
+	s.posts[i].NumOfThumb += sumReactions(deltas)
+	return nil
+}
+
+func (s *MemoryPostStore) UpdatePost(ctx context.Context, post models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(post.PostID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.slugTaken(post) {
+		return ErrDuplicate
+	}
+	s.posts[i].Title = post.Title
+	s.posts[i].Tags = post.Tags
+	s.posts[i].Content = post.Content
+	s.posts[i].Status = post.EffectiveStatus()
+	s.posts[i].PublishAt = post.PublishAt
+	s.posts[i].Slug = post.Slug
+	s.posts[i].OldSlugs = post.OldSlugs
+	s.posts[i].LastUpdatedTime = post.LastUpdatedTime
+	return nil
+}
+
+func (s *MemoryPostStore) TrashPost(ctx context.Context, id primitive.ObjectID, trash models.Trash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || s.posts[i].Trash != nil {
+		return ErrNotFound
+	}
+	s.posts[i].Trash = &trash
+	return nil
+}
+
+func (s *MemoryPostStore) RestorePost(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || s.posts[i].Trash == nil {
+		return ErrNotFound
+	}
+	s.posts[i].Trash = nil
+	return nil
+}
+
+func (s *MemoryPostStore) ListTrashedPosts(ctx context.Context, q TrashQuery) (PostPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, sortTrash)
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	s.mu.RLock()
+	matched := make([]models.Post, 0)
+	for _, post := range s.posts {
+		if q.matches(post.Trash, post.Username) {
+			matched = append(matched, post)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return trashCursor(matched[i].Trash, matched[i].PostID).follows(matched[j].Trash.DeletedTime.UnixNano(), matched[j].PostID)
+	})
+
+	posts := make([]models.Post, 0, q.Limit+1)
+	for _, post := range matched {
+		if after != nil && !after.follows(post.Trash.DeletedTime.UnixNano(), post.PostID) {
+			continue
+		}
+		posts = append(posts, post)
+		if len(posts) > q.Limit {
+			break
+		}
+	}
+	return newTrashedPostPage(posts, q, int64(len(matched))), nil
+}
+
+func (s *MemoryPostStore) PublishDuePosts(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]primitive.ObjectID, 0)
+	for i, post := range s.posts {
+		if post.Trash != nil || post.Status != models.StatusScheduled || post.PublishAt == nil || post.PublishAt.After(now) {
+			continue
+		}
+		s.posts[i].Status = models.StatusPublished
+		s.posts[i].PublishAt = nil
+		s.posts[i].LastUpdatedTime = now
+		ids = append(ids, post.PostID)
+	}
+	return ids, nil
+}
+
+// slugTaken reports whether another post has the slug of post, like the
+// unique index of the MongoDB store does.
+func (s *MemoryPostStore) slugTaken(post models.Post) bool {
+	if post.Slug == "" {
+		return false
+	}
+	for _, other := range s.posts {
+		if other.PostID != post.PostID && other.Slug == post.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryPostStore) indexOf(id primitive.ObjectID) int {
+	for i := range s.posts {
+		if s.posts[i].PostID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryPostStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, post := range s.posts {
+		if post.EffectiveStatus() != models.StatusPublished || post.Trash != nil {
+			continue
+		}
+		for _, tag := range post.Tags {
+			counts[tag]++
+		}
+	}
+	return sortTagCounts(counts), nil
+}
+
+func (s *MemoryPostStore) RelatedTags(ctx context.Context, slug string) ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, post := range s.posts {
+		if !containsTag(post.Tags, slug) || post.EffectiveStatus() != models.StatusPublished || post.Trash != nil {
+			continue
+		}
+		for _, tag := range post.Tags {
+			if tag != slug {
+				counts[tag]++
+			}
+		}
+	}
+	return sortTagCounts(counts), nil
+}
+
+func (s *MemoryPostStore) ReplaceTags(ctx context.Context, from []string, to string, now time.Time) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]primitive.ObjectID, 0)
+	for i, post := range s.posts {
+		replaced := false
+		tags := make([]string, 0, len(post.Tags))
+		for _, tag := range post.Tags {
+			if containsTag(from, tag) {
+				replaced = true
+				if tag != to {
+					continue
+				}
+			}
+			tags = append(tags, tag)
+		}
+		if !replaced {
+			continue
+		}
+		if !containsTag(tags, to) {
+			tags = append(tags, to)
+		}
+		s.posts[i].Tags = tags
+		s.posts[i].LastUpdatedTime = now
+		ids = append(ids, post.PostID)
+	}
+	return ids, nil
+}
+
+// withoutSlug returns slugs without slug.
+func withoutSlug(slugs []string, slug string) []string {
+	kept := make([]string, 0, len(slugs))
+	for _, s := range slugs {
+		if s != slug {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func containsTag(tags []string, slug string) bool {
+	for _, tag := range tags {
+		if tag == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// sortTagCounts orders counts like the MongoDB aggregation does, most used
+// first.
+func sortTagCounts(counts map[string]int64) []TagCount {
+	sorted := make([]TagCount, 0, len(counts))
+	for slug, count := range counts {
+		sorted = append(sorted, TagCount{Slug: slug, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Slug < sorted[j].Slug
+	})
+	return sorted
+}
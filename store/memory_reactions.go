@@ -0,0 +1,123 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryReactionStore is a ReactionStore that keeps reactions in process
+// memory. It is meant for tests and local development.
+type MemoryReactionStore struct {
+	mu        sync.RWMutex
+	reactions map[reactionKey]models.Reaction
+}
+
+type reactionKey struct {
+	target   primitive.ObjectID
+	username string
+}
+
+func NewMemoryReactionStore() *MemoryReactionStore {
+	return &MemoryReactionStore{reactions: make(map[reactionKey]models.Reaction)}
+}
+
+func (s *MemoryReactionStore) SetReaction(ctx context.Context, r models.Reaction) (models.ReactionType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := reactionKey{r.TargetID, r.Username}
+	previous, ok := s.reactions[key]
+	if ok {
+		r.ReactionID = previous.ReactionID
+	}
+	s.reactions[key] = r
+	return previous.Type, nil
+}
+
+func (s *MemoryReactionStore) RemoveReaction(ctx context.Context, targetID primitive.ObjectID, username string) (models.ReactionType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := reactionKey{targetID, username}
+	previous, ok := s.reactions[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	delete(s.reactions, key)
+	return previous.Type, nil
+}
+
+func (s *MemoryReactionStore) ListReactions(ctx context.Context, targetID primitive.ObjectID) ([]models.Reaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reactions := make([]models.Reaction, 0)
+	for key, reaction := range s.reactions {
+		if key.target == targetID {
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Slice(reactions, func(i, j int) bool {
+		a, b := reactions[i], reactions[j]
+		if !a.CreatedTime.Equal(b.CreatedTime) {
+			return a.CreatedTime.Before(b.CreatedTime)
+		}
+		return a.ReactionID.Hex() < b.ReactionID.Hex()
+	})
+	return reactions, nil
+}
+
+func (s *MemoryReactionStore) UserReactions(ctx context.Context, username string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]models.ReactionType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reacted := make(map[primitive.ObjectID]models.ReactionType)
+	for _, id := range targetIDs {
+		if reaction, ok := s.reactions[reactionKey{id, username}]; ok {
+			reacted[id] = reaction.Type
+		}
+	}
+	return reacted, nil
+}
+
+func (s *MemoryReactionStore) DeleteReactions(ctx context.Context, targetIDs []primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := make(map[primitive.ObjectID]bool, len(targetIDs))
+	for _, id := range targetIDs {
+		targets[id] = true
+	}
+	for key := range s.reactions {
+		if targets[key.target] {
+			delete(s.reactions, key)
+		}
+	}
+	return nil
+}
+
+// addReactions returns a copy of counts with deltas applied, so that
+// documents handed out earlier keep their counters.
+func addReactions(counts, deltas map[models.ReactionType]int64) map[models.ReactionType]int64 {
+	sum := make(map[models.ReactionType]int64, len(counts)+len(deltas))
+	for reactionType, n := range counts {
+		sum[reactionType] = n
+	}
+	for reactionType, delta := range deltas {
+		sum[reactionType] += delta
+	}
+	return sum
+}
+
+// sumReactions returns the change of the total that deltas cause.
+func sumReactions(deltas map[models.ReactionType]int64) int64 {
+	var sum int64
+	for _, delta := range deltas {
+		sum += delta
+	}
+	return sum
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRevisionStore is a RevisionStore that keeps revisions in process
+// memory. It is meant for tests and local development.
+type MemoryRevisionStore struct {
+	mu        sync.RWMutex
+	revisions []models.Revision
+}
+
+func NewMemoryRevisionStore() *MemoryRevisionStore {
+	return &MemoryRevisionStore{}
+}
+
+func (s *MemoryRevisionStore) InsertRevision(ctx context.Context, revision models.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revisions = append(s.revisions, revision)
+	return nil
+}
+
+func (s *MemoryRevisionStore) ListRevisions(ctx context.Context, postID primitive.ObjectID) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := make([]models.Revision, 0)
+	for i := len(s.revisions) - 1; i >= 0; i-- {
+		if s.revisions[i].PostID == postID {
+			revisions = append(revisions, s.revisions[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (s *MemoryRevisionStore) GetRevision(ctx context.Context, id primitive.ObjectID) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, revision := range s.revisions {
+		if revision.RevisionID == id {
+			return revision, nil
+		}
+	}
+	return models.Revision{}, ErrNotFound
+}
+
+func (s *MemoryRevisionStore) DeleteRevisions(ctx context.Context, postID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := make([]models.Revision, 0, len(s.revisions))
+	for _, revision := range s.revisions {
+		if revision.PostID != postID {
+			kept = append(kept, revision)
+		}
+	}
+	s.revisions = kept
+	return nil
+}
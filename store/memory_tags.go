@@ -0,0 +1,85 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryTagStore is a TagStore that keeps the taxonomy in process memory.
+// It is meant for tests and local development.
+type MemoryTagStore struct {
+	mu   sync.RWMutex
+	tags map[string]models.Tag
+}
+
+func NewMemoryTagStore() *MemoryTagStore {
+	return &MemoryTagStore{tags: make(map[string]models.Tag)}
+}
+
+func (s *MemoryTagStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make([]models.Tag, 0, len(s.tags))
+	for _, tag := range s.tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Slug < tags[j].Slug })
+	return tags, nil
+}
+
+func (s *MemoryTagStore) GetTag(ctx context.Context, slug string) (models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, ok := s.tags[slug]
+	if !ok {
+		return tag, ErrNotFound
+	}
+	return tag, nil
+}
+
+func (s *MemoryTagStore) EnsureTag(ctx context.Context, tag models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tags[tag.Slug]; !ok {
+		s.tags[tag.Slug] = tag
+	}
+	return nil
+}
+
+func (s *MemoryTagStore) SaveTag(ctx context.Context, tag models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tags[tag.Slug] = tag
+	return nil
+}
+
+func (s *MemoryTagStore) DeleteTag(ctx context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tags[slug]; !ok {
+		return ErrNotFound
+	}
+	delete(s.tags, slug)
+	return nil
+}
+
+func (s *MemoryTagStore) ResolveTag(ctx context.Context, slug string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, tag := range s.tags {
+		for _, alias := range tag.Aliases {
+			if alias == slug {
+				return tag.Slug, nil
+			}
+		}
+	}
+	return slug, nil
+}
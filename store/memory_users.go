@@ -0,0 +1,92 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryUserStore is a UserStore that keeps users in process memory. It is
+// meant for tests and local development.
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[string]models.User)}
+}
+
+func (s *MemoryUserStore) FindUserByUsername(ctx context.Context, username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) InsertUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; ok {
+		return ErrDuplicate
+	}
+	s.users[user.Username] = user
+	return nil
+}
+
+func (s *MemoryUserStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *MemoryUserStore) UpdateUserRole(ctx context.Context, username string, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	s.users[username] = user
+	return nil
+}
+
+func (s *MemoryUserStore) SetUserDisabled(ctx context.Context, username string, disabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Disabled = disabled
+	s.users[username] = user
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateUserPassword(ctx context.Context, username, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = hash
+	s.users[username] = user
+	return nil
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoCommentStore is a CommentStore backed by a MongoDB collection.
+type MongoCommentStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCommentStore(collection *mongo.Collection) *MongoCommentStore {
+	return &MongoCommentStore{collection: collection}
+}
+
+// commentOrder lists comments oldest first with the ID as tie breaker.
+var commentOrder = bson.D{{Key: "commentCreatedTime", Value: 1}, {Key: "_id", Value: 1}}
+
+func (s *MongoCommentStore) ListCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]models.Comment, error) {
+	return s.find(ctx, bson.M{"commentToID": postID}, options.Find().SetSort(commentOrder))
+}
+
+func (s *MongoCommentStore) ListCommentThreads(ctx context.Context, postID primitive.ObjectID, limit, offset int) (CommentThreads, error) {
+	var threads CommentThreads
+	answered, err := s.collection.Distinct(ctx, "rootID", bson.M{"commentToID": postID, "rootID": bson.M{"$exists": true}, "trash": notTrashed})
+	if err != nil {
+		return threads, err
+	}
+	top := bson.M{
+		"commentToID": postID,
+		"parentID":    bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"trash": notTrashed, "deleted": bson.M{"$ne": true}},
+			bson.M{"_id": bson.M{"$in": answered}},
+		},
+	}
+
+	total, err := s.collection.CountDocuments(ctx, top)
+	if err != nil {
+		return threads, err
+	}
+	threads.Total = total
+
+	opts := options.Find().SetSort(commentOrder).SetSkip(int64(offset)).SetLimit(int64(limit))
+	if threads.Roots, err = s.find(ctx, top, opts); err != nil {
+		return threads, err
+	}
+
+	roots := make([]primitive.ObjectID, 0, len(threads.Roots))
+	for _, root := range threads.Roots {
+		roots = append(roots, root.CommentID)
+	}
+	threads.Replies, err = s.find(ctx, bson.M{"rootID": bson.M{"$in": roots}}, options.Find().SetSort(commentOrder))
+	return threads, err
+}
+
+func (s *MongoCommentStore) CountReplies(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return s.collection.CountDocuments(ctx, bson.M{"parentID": id})
+}
+
+func (s *MongoCommentStore) TombstoneComment(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.UpdateByID(ctx, id, bson.M{
+		"$set":   bson.M{"deleted": true, "username": "", "commentContent": "", "numOfThumb": 0},
+		"$unset": bson.M{"trash": "", "reactions": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCommentStore) TrashComment(ctx context.Context, id primitive.ObjectID, trash models.Trash) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "trash": notTrashed}, bson.M{"$set": bson.M{"trash": trash}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCommentStore) RestoreComment(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "trash": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"trash": ""}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCommentStore) ListTrashedComments(ctx context.Context, q TrashQuery) (CommentPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, sortTrash)
+	if err != nil {
+		return CommentPage{}, err
+	}
+	total, err := s.collection.CountDocuments(ctx, trashedFilter(q, nil))
+	if err != nil {
+		return CommentPage{}, err
+	}
+
+	opts := options.Find().SetSort(trashOrder).SetLimit(int64(q.Limit + 1))
+	comments, err := s.find(ctx, trashedFilter(q, after), opts)
+	if err != nil {
+		return CommentPage{}, err
+	}
+	return newTrashedCommentPage(comments, q, total), nil
+}
+
+func (s *MongoCommentStore) DeleteCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	filter := bson.M{"commentToID": postID}
+	cur, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	if _, err := s.collection.DeleteMany(ctx, filter); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+func (s *MongoCommentStore) find(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]models.Comment, error) {
+	cur, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]models.Comment, 0)
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (s *MongoCommentStore) GetComment(ctx context.Context, id primitive.ObjectID) (models.Comment, error) {
+	var comment models.Comment
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&comment)
+	if err == mongo.ErrNoDocuments {
+		return comment, ErrNotFound
+	}
+	return comment, err
+}
+
+func (s *MongoCommentStore) InsertComment(ctx context.Context, comment models.Comment) error {
+	_, err := s.collection.InsertOne(ctx, comment)
+	return err
+}
+
+func (s *MongoCommentStore) UpdateCommentContent(ctx context.Context, id primitive.ObjectID, content string) error {
+	res, err := s.collection.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{"commentContent": content},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCommentStore) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCommentStore) IncrementCommentReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	res, err := s.collection.UpdateByID(ctx, id, bson.M{
+		"$inc": reactionIncrements("numOfThumb", "reactions", deltas),
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoPostStore is a PostStore backed by a MongoDB collection.
+type MongoPostStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPostStore(collection *mongo.Collection) *MongoPostStore {
+	return &MongoPostStore{collection: collection}
+}
+
+// EnsureIndex creates the unique index on slugs and the index that finds
+// posts by their former slugs.
+func (s *MongoPostStore) EnsureIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetName("post_slug").SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "oldSlugs", Value: 1}},
+			Options: options.Index().SetName("post_old_slugs"),
+		},
+	})
+	return err
+}
+
+func (s *MongoPostStore) ListPosts(ctx context.Context, q PostQuery) (PostPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	filter := bson.M{"trash": notTrashed}
+	if q.Tag != "" {
+		filter["postTags"] = q.Tag
+	}
+	if q.Author != "" {
+		filter["username"] = q.Author
+	}
+	if q.Status != "" {
+		filter["status"] = statusFilter(q.Status)
+	}
+	created := bson.M{}
+	if !q.From.IsZero() {
+		created["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		created["$lt"] = q.To
+	}
+	if len(created) > 0 {
+		filter["postCreatedTime"] = created
+	}
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	field := q.Sort.field()
+	if after != nil {
+		value := q.Sort.value(after.Value)
+		filter["$or"] = bson.A{
+			bson.M{field: bson.M{"$lt": value}},
+			bson.M{field: value, "_id": bson.M{"$lt": after.ID}},
+		}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(q.Limit + 1))
+	cur, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return PostPage{}, err
+	}
+	posts := make([]models.Post, 0, q.Limit+1)
+	if err := cur.All(ctx, &posts); err != nil {
+		return PostPage{}, err
+	}
+
+	return newPostPage(posts, q, total), nil
+}
+
+func (s *MongoPostStore) GetPost(ctx context.Context, id primitive.ObjectID) (models.Post, error) {
+	var post models.Post
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&post)
+	if err == mongo.ErrNoDocuments {
+		return post, ErrNotFound
+	}
+	return post, err
+}
+
+func (s *MongoPostStore) GetPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	var post models.Post
+	err := s.collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&post)
+	if err == mongo.ErrNoDocuments {
+		err = s.collection.FindOne(ctx, bson.M{"oldSlugs": slug}).Decode(&post)
+	}
+	if err == mongo.ErrNoDocuments {
+		return post, ErrNotFound
+	}
+	return post, err
+}
+
+func (s *MongoPostStore) ReleaseSlug(ctx context.Context, slug string, owner primitive.ObjectID) error {
+	_, err := s.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$ne": owner}, "oldSlugs": slug},
+		bson.M{"$pull": bson.M{"oldSlugs": slug}})
+	return err
+}
+
+// notTrashed matches the trash field of posts and comments that are not in
+// the trash.
+var notTrashed = bson.M{"$exists": false}
+
+// trashedFilter selects the posts or comments in the trash that pass the
+// filters of q and follow after, which may be nil.
+func trashedFilter(q TrashQuery, after *cursor) bson.M {
+	filter := bson.M{"trash": bson.M{"$exists": true}}
+	if q.Author != "" {
+		filter["username"] = q.Author
+	}
+	if !q.Before.IsZero() {
+		filter["trash.deletedTime"] = bson.M{"$lt": q.Before}
+	}
+	if after != nil {
+		deleted := time.Unix(0, after.Value)
+		filter["$or"] = bson.A{
+			bson.M{"trash.deletedTime": bson.M{"$lt": deleted}},
+			bson.M{"trash.deletedTime": deleted, "_id": bson.M{"$lt": after.ID}},
+		}
+	}
+	return filter
+}
+
+// trashOrder lists trashed documents most recently deleted first.
+var trashOrder = bson.D{{Key: "trash.deletedTime", Value: -1}, {Key: "_id", Value: -1}}
+
+// statusFilter matches the status field of posts with status. Posts without
+// one count as published.
+func statusFilter(status models.PostStatus) interface{} {
+	if status == models.StatusPublished {
+		return bson.M{"$in": bson.A{status, nil}}
+	}
+	return status
+}
+
+func (s *MongoPostStore) SamplePosts(ctx context.Context, n int) ([]models.Post, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": statusFilter(models.StatusPublished), "trash": notTrashed}}},
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: n}}}},
+	}
+	cur, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]models.Post, 0, n)
+	if err := cur.All(ctx, &posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (s *MongoPostStore) InsertPost(ctx context.Context, post models.Post) error {
+	_, err := s.collection.InsertOne(ctx, post)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoPostStore) DeletePost(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPostStore) IncrementPostReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error {
+	res, err := s.collection.UpdateByID(ctx, id, bson.M{
+		"$inc": reactionIncrements("postNumOfThumb", "postReactions", deltas),
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPostStore) UpdatePost(ctx context.Context, post models.Post) error {
+	res, err := s.collection.UpdateByID(ctx, post.PostID, bson.M{
+		"$set": bson.M{
+			"postTitle":           post.Title,
+			"postTags":            post.Tags,
+			"postContent":         post.Content,
+			"status":              post.EffectiveStatus(),
+			"publishAt":           post.PublishAt,
+			"slug":                post.Slug,
+			"oldSlugs":            post.OldSlugs,
+			"postLastUpdatedTime": post.LastUpdatedTime,
+		},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPostStore) TrashPost(ctx context.Context, id primitive.ObjectID, trash models.Trash) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "trash": notTrashed}, bson.M{"$set": bson.M{"trash": trash}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPostStore) RestorePost(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "trash": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"trash": ""}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPostStore) ListTrashedPosts(ctx context.Context, q TrashQuery) (PostPage, error) {
+	q = q.normalize()
+	after, err := decodeCursor(q.Cursor, sortTrash)
+	if err != nil {
+		return PostPage{}, err
+	}
+	total, err := s.collection.CountDocuments(ctx, trashedFilter(q, nil))
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	opts := options.Find().SetSort(trashOrder).SetLimit(int64(q.Limit + 1))
+	cur, err := s.collection.Find(ctx, trashedFilter(q, after), opts)
+	if err != nil {
+		return PostPage{}, err
+	}
+	posts := make([]models.Post, 0, q.Limit+1)
+	if err := cur.All(ctx, &posts); err != nil {
+		return PostPage{}, err
+	}
+	return newTrashedPostPage(posts, q, total), nil
+}
+
+func (s *MongoPostStore) PublishDuePosts(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	filter := bson.M{"status": models.StatusScheduled, "publishAt": bson.M{"$lte": now}, "trash": notTrashed}
+	cur, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		// a post rescheduled in the meantime is left alone
+		res, err := s.collection.UpdateOne(ctx, bson.M{"_id": doc.ID, "status": models.StatusScheduled, "publishAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"status": models.StatusPublished, "postLastUpdatedTime": now}, "$unset": bson.M{"publishAt": ""}})
+		if err != nil {
+			return ids, err
+		}
+		if res.ModifiedCount > 0 {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MongoPostStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	return s.countTags(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": statusFilter(models.StatusPublished), "trash": notTrashed}}},
+		{{Key: "$unwind", Value: "$postTags"}},
+	})
+}
+
+func (s *MongoPostStore) RelatedTags(ctx context.Context, slug string) ([]TagCount, error) {
+	return s.countTags(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"postTags": slug, "status": statusFilter(models.StatusPublished), "trash": notTrashed}}},
+		{{Key: "$unwind", Value: "$postTags"}},
+		{{Key: "$match", Value: bson.M{"postTags": bson.M{"$ne": slug}}}},
+	})
+}
+
+// countTags groups the unwound tags of pipeline and counts them, most used
+// first.
+func (s *MongoPostStore) countTags(ctx context.Context, pipeline mongo.Pipeline) ([]TagCount, error) {
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$postTags", "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	)
+	cur, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]TagCount, 0)
+	if err := cur.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (s *MongoPostStore) ReplaceTags(ctx context.Context, from []string, to string, now time.Time) ([]primitive.ObjectID, error) {
+	filter := bson.M{"postTags": bson.M{"$in": from}}
+	cur, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	pull := make([]string, 0, len(from))
+	for _, slug := range from {
+		if slug != to {
+			pull = append(pull, slug)
+		}
+	}
+	// $addToSet and $pull cannot touch the same field in one update, so a
+	// pipeline drops the old tags and appends the new one unless it is there
+	kept := bson.M{"$filter": bson.M{
+		"input": "$postTags",
+		"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", pull}}}},
+	}}
+	tags := bson.M{"$cond": bson.A{
+		bson.M{"$in": bson.A{to, "$postTags"}},
+		kept,
+		bson.M{"$concatArrays": bson.A{kept, bson.A{to}}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"postTags": tags, "postLastUpdatedTime": now}}}}
+	if _, err := s.collection.UpdateMany(ctx, filter, update); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoReactionStore is a ReactionStore backed by a MongoDB collection.
+type MongoReactionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoReactionStore(collection *mongo.Collection) *MongoReactionStore {
+	return &MongoReactionStore{collection: collection}
+}
+
+// EnsureIndex creates the unique index that keeps one reaction per user
+// and target.
+func (s *MongoReactionStore) EnsureIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "targetID", Value: 1}, {Key: "username", Value: 1}},
+		Options: options.Index().SetName("reaction_target_user").SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoReactionStore) SetReaction(ctx context.Context, r models.Reaction) (models.ReactionType, error) {
+	filter := bson.M{"targetID": r.TargetID, "username": r.Username}
+	update := bson.M{
+		"$set":         bson.M{"type": r.Type, "reactionCreatedTime": r.CreatedTime},
+		"$setOnInsert": bson.M{"_id": r.ReactionID},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	var previous models.Reaction
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent request inserted the reaction first, update it instead
+		err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	}
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	return previous.Type, err
+}
+
+func (s *MongoReactionStore) RemoveReaction(ctx context.Context, targetID primitive.ObjectID, username string) (models.ReactionType, error) {
+	var previous models.Reaction
+	err := s.collection.FindOneAndDelete(ctx, bson.M{"targetID": targetID, "username": username}).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrNotFound
+	}
+	return previous.Type, err
+}
+
+func (s *MongoReactionStore) ListReactions(ctx context.Context, targetID primitive.ObjectID) ([]models.Reaction, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "reactionCreatedTime", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := s.collection.Find(ctx, bson.M{"targetID": targetID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	reactions := make([]models.Reaction, 0)
+	if err := cur.All(ctx, &reactions); err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+func (s *MongoReactionStore) UserReactions(ctx context.Context, username string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]models.ReactionType, error) {
+	reacted := make(map[primitive.ObjectID]models.ReactionType)
+	if len(targetIDs) == 0 {
+		return reacted, nil
+	}
+	cur, err := s.collection.Find(ctx, bson.M{"username": username, "targetID": bson.M{"$in": targetIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var reactions []models.Reaction
+	if err := cur.All(ctx, &reactions); err != nil {
+		return nil, err
+	}
+	for _, reaction := range reactions {
+		reacted[reaction.TargetID] = reaction.Type
+	}
+	return reacted, nil
+}
+
+func (s *MongoReactionStore) DeleteReactions(ctx context.Context, targetIDs []primitive.ObjectID) error {
+	if len(targetIDs) == 0 {
+		return nil
+	}
+	_, err := s.collection.DeleteMany(ctx, bson.M{"targetID": bson.M{"$in": targetIDs}})
+	return err
+}
+
+// reactionIncrements builds the $inc document that applies deltas to the
+// per type counters below countsField and their sum to totalField.
+func reactionIncrements(totalField, countsField string, deltas map[models.ReactionType]int64) bson.M {
+	inc := bson.M{totalField: sumReactions(deltas)}
+	for reactionType, delta := range deltas {
+		inc[countsField+"."+string(reactionType)] = delta
+	}
+	return inc
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRevisionStore is a RevisionStore backed by a MongoDB collection.
+type MongoRevisionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRevisionStore(collection *mongo.Collection) *MongoRevisionStore {
+	return &MongoRevisionStore{collection: collection}
+}
+
+func (s *MongoRevisionStore) InsertRevision(ctx context.Context, revision models.Revision) error {
+	_, err := s.collection.InsertOne(ctx, revision)
+	return err
+}
+
+func (s *MongoRevisionStore) ListRevisions(ctx context.Context, postID primitive.ObjectID) ([]models.Revision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "revisionCreatedTime", Value: -1}, {Key: "_id", Value: -1}})
+	cur, err := s.collection.Find(ctx, bson.M{"postID": postID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]models.Revision, 0)
+	if err := cur.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (s *MongoRevisionStore) GetRevision(ctx context.Context, id primitive.ObjectID) (models.Revision, error) {
+	var revision models.Revision
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return revision, ErrNotFound
+	}
+	return revision, err
+}
+
+func (s *MongoRevisionStore) DeleteRevisions(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"postID": postID})
+	return err
+}
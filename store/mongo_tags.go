@@ -0,0 +1,81 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTagStore is a TagStore backed by a MongoDB collection.
+type MongoTagStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTagStore(collection *mongo.Collection) *MongoTagStore {
+	return &MongoTagStore{collection: collection}
+}
+
+func (s *MongoTagStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	cur, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]models.Tag, 0)
+	if err := cur.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *MongoTagStore) GetTag(ctx context.Context, slug string) (models.Tag, error) {
+	var tag models.Tag
+	err := s.collection.FindOne(ctx, bson.M{"_id": slug}).Decode(&tag)
+	if err == mongo.ErrNoDocuments {
+		return tag, ErrNotFound
+	}
+	return tag, err
+}
+
+func (s *MongoTagStore) EnsureTag(ctx context.Context, tag models.Tag) error {
+	if tag.Aliases == nil {
+		tag.Aliases = []string{}
+	}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": tag.Slug}, bson.M{
+		"$setOnInsert": bson.M{"name": tag.Name, "aliases": tag.Aliases},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *MongoTagStore) SaveTag(ctx context.Context, tag models.Tag) error {
+	if tag.Aliases == nil {
+		tag.Aliases = []string{}
+	}
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": tag.Slug}, tag, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoTagStore) DeleteTag(ctx context.Context, slug string) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": slug})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoTagStore) ResolveTag(ctx context.Context, slug string) (string, error) {
+	var tag models.Tag
+	err := s.collection.FindOne(ctx, bson.M{"aliases": slug}).Decode(&tag)
+	if err == mongo.ErrNoDocuments {
+		return slug, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return tag.Slug, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"blogo/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoUserStore is a UserStore backed by a MongoDB collection.
+type MongoUserStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserStore(collection *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{collection: collection}
+}
+
+func (s *MongoUserStore) FindUserByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func (s *MongoUserStore) InsertUser(ctx context.Context, user models.User) error {
+	_, err := s.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoUserStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+	cur, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]models.User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *MongoUserStore) UpdateUserRole(ctx context.Context, username string, role models.Role) error {
+	return s.set(ctx, username, bson.M{"role": role})
+}
+
+func (s *MongoUserStore) SetUserDisabled(ctx context.Context, username string, disabled bool) error {
+	return s.set(ctx, username, bson.M{"disabled": disabled})
+}
+
+func (s *MongoUserStore) UpdateUserPassword(ctx context.Context, username, hash string) error {
+	return s.set(ctx, username, bson.M{"password": hash})
+}
+
+func (s *MongoUserStore) set(ctx context.Context, username string, fields bson.M) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
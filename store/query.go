@@ -0,0 +1,261 @@
+package store
+
+import (
+	"blogo/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostSort is the order in which posts are listed. All orders are
+// descending with the post ID as tie breaker.
+type PostSort string
+
+const (
+	SortCreated PostSort = "created"
+	SortUpdated PostSort = "updated"
+	SortThumbs  PostSort = "thumbs"
+)
+
+// Valid reports whether s is one of the known orders.
+func (s PostSort) Valid() bool {
+	return s == SortCreated || s == SortUpdated || s == SortThumbs
+}
+
+// field returns the document field the order is based on.
+func (s PostSort) field() string {
+	switch s {
+	case SortUpdated:
+		return "postLastUpdatedTime"
+	case SortThumbs:
+		return "postNumOfThumb"
+	}
+	return "postCreatedTime"
+}
+
+// key returns the value of post the order is based on.
+func (s PostSort) key(post models.Post) int64 {
+	switch s {
+	case SortUpdated:
+		return post.LastUpdatedTime.UnixNano()
+	case SortThumbs:
+		return post.NumOfThumb
+	}
+	return post.CreatedTime.UnixNano()
+}
+
+// value converts a key back into the type stored in the document.
+func (s PostSort) value(key int64) interface{} {
+	if s == SortThumbs {
+		return key
+	}
+	return time.Unix(0, key)
+}
+
+// less orders a before b by key and then by ID, ascending.
+func (s PostSort) less(a, b models.Post) bool {
+	ka, kb := s.key(a), s.key(b)
+	if ka != kb {
+		return ka < kb
+	}
+	return a.PostID.Hex() < b.PostID.Hex()
+}
+
+// before reports whether c comes before post in the descending order, that
+// is whether post belongs to the pages following c.
+func (s PostSort) before(c cursor, post models.Post) bool {
+	return c.follows(s.key(post), post.PostID)
+}
+
+// matches reports whether post passes the filters of q.
+func (q PostQuery) matches(post models.Post) bool {
+	if post.Trash != nil {
+		return false
+	}
+	if q.Author != "" && post.Username != q.Author {
+		return false
+	}
+	if q.Status != "" && post.EffectiveStatus() != q.Status {
+		return false
+	}
+	if !q.From.IsZero() && post.CreatedTime.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !post.CreatedTime.Before(q.To) {
+		return false
+	}
+	return q.Tag == "" || containsTag(post.Tags, q.Tag)
+}
+
+// PostQuery selects one page of posts. Zero fields do not filter. Posts in
+// the trash are never listed.
+type PostQuery struct {
+	Limit  int
+	Cursor string
+	Sort   PostSort
+	Tag    string
+	Author string
+	// Status selects the posts in one state of their lifecycle.
+	Status models.PostStatus
+	// From and To bound the creation time, From inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// PostPage is one page of a post listing.
+type PostPage struct {
+	Posts []models.Post `json:"posts"`
+	// NextCursor continues the listing after the last post of this page. It
+	// is empty on the last page.
+	NextCursor string `json:"nextCursor"`
+	// Total counts the posts matching the filters on all pages.
+	Total int64 `json:"total"`
+}
+
+// ErrInvalidCursor is returned for cursors that were not produced by a
+// listing with the same sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks the position after which the next page starts.
+type cursor struct {
+	Sort  PostSort           `json:"s"`
+	Value int64              `json:"v"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// follows reports whether the document with key and id comes after c in
+// the descending order.
+func (c cursor) follows(key int64, id primitive.ObjectID) bool {
+	if key != c.Value {
+		return key < c.Value
+	}
+	return id.Hex() < c.ID.Hex()
+}
+
+func encodeCursor(sort PostSort, post models.Post) string {
+	return cursor{Sort: sort, Value: sort.key(post), ID: post.PostID}.encode()
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort PostSort) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// newPostPage builds the page from up to q.Limit+1 sorted posts, the extra
+// one only telling that there is a next page.
+func newPostPage(posts []models.Post, q PostQuery, total int64) PostPage {
+	page := PostPage{Posts: posts, Total: total}
+	if len(posts) > q.Limit {
+		page.Posts = posts[:q.Limit]
+		page.NextCursor = encodeCursor(q.Sort, page.Posts[q.Limit-1])
+	}
+	return page
+}
+
+// normalize fills in the defaults of q.
+func (q PostQuery) normalize() PostQuery {
+	if q.Sort == "" {
+		q.Sort = SortCreated
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return q
+}
+
+// sortTrash orders posts and comments by the time they were moved to the
+// trash. Only the trash listings use it.
+const sortTrash PostSort = "trash"
+
+// TrashQuery selects one page of the posts or comments in the trash, most
+// recently deleted first. A non-empty Author selects the content of one
+// user and a non-zero Before the content deleted before that time.
+type TrashQuery struct {
+	Limit  int
+	Cursor string
+	Author string
+	Before time.Time
+}
+
+// matches reports whether content by username with trash passes the
+// filters of q.
+func (q TrashQuery) matches(trash *models.Trash, username string) bool {
+	if trash == nil || q.Author != "" && username != q.Author {
+		return false
+	}
+	return q.Before.IsZero() || trash.DeletedTime.Before(q.Before)
+}
+
+// normalize fills in the defaults of q.
+func (q TrashQuery) normalize() TrashQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return q
+}
+
+// trashCursor marks the position after content deleted with trash.
+func trashCursor(trash *models.Trash, id primitive.ObjectID) cursor {
+	return cursor{Sort: sortTrash, Value: trash.DeletedTime.UnixNano(), ID: id}
+}
+
+// CommentPage is one page of a comment listing.
+type CommentPage struct {
+	Comments []models.Comment `json:"comments"`
+	// NextCursor continues the listing after the last comment of this
+	// page. It is empty on the last page.
+	NextCursor string `json:"nextCursor"`
+	// Total counts the comments matching the filters on all pages.
+	Total int64 `json:"total"`
+}
+
+// newTrashedPostPage is newPostPage for a listing of the trash.
+func newTrashedPostPage(posts []models.Post, q TrashQuery, total int64) PostPage {
+	page := PostPage{Posts: posts, Total: total}
+	if len(posts) > q.Limit {
+		page.Posts = posts[:q.Limit]
+		last := page.Posts[q.Limit-1]
+		page.NextCursor = trashCursor(last.Trash, last.PostID).encode()
+	}
+	return page
+}
+
+// newTrashedCommentPage is newTrashedPostPage for comments.
+func newTrashedCommentPage(comments []models.Comment, q TrashQuery, total int64) CommentPage {
+	page := CommentPage{Comments: comments, Total: total}
+	if len(comments) > q.Limit {
+		page.Comments = comments[:q.Limit]
+		last := page.Comments[q.Limit-1]
+		page.NextCursor = trashCursor(last.Trash, last.CommentID).encode()
+	}
+	return page
+}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"blogo/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	post := models.Post{
+		PostID:          primitive.NewObjectID(),
+		CreatedTime:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		LastUpdatedTime: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC),
+		NumOfThumb:      7,
+	}
+	for _, sort := range []PostSort{SortCreated, SortUpdated, SortThumbs} {
+		c, err := decodeCursor(encodeCursor(sort, post), sort)
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if c.Sort != sort || c.Value != sort.key(post) || c.ID != post.PostID {
+			t.Errorf("%s: decoded %+v", sort, *c)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	if c, err := decodeCursor("", SortCreated); c != nil || err != nil {
+		t.Errorf("decodeCursor(\"\") = %v, %v, want nil, nil", c, err)
+	}
+
+	post := models.Post{PostID: primitive.NewObjectID(), CreatedTime: time.Now()}
+	for name, s := range map[string]string{
+		"other sort": encodeCursor(SortThumbs, post),
+		"not base64": "%%%",
+		"not json":   "bm90IGpzb24",
+	} {
+		if _, err := decodeCursor(s, SortCreated); err != ErrInvalidCursor {
+			t.Errorf("%s: got %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package store defines the persistence interfaces used by the handlers
+// together with a MongoDB and an in-memory implementation of each.
+package store
+
+import (
+	"blogo/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when the requested document does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a document with the same unique key
+	// already exists.
+	ErrDuplicate = errors.New("already exists")
+)
+
+// PostStore persists blog posts.
+type PostStore interface {
+	// ListPosts returns one page of the posts matching q.
+	ListPosts(ctx context.Context, q PostQuery) (PostPage, error)
+	GetPost(ctx context.Context, id primitive.ObjectID) (models.Post, error)
+	// GetPostBySlug returns the post whose slug is slug or, when there is
+	// none, the post whose slug was slug before it changed.
+	GetPostBySlug(ctx context.Context, slug string) (models.Post, error)
+	// ReleaseSlug drops slug from the former slugs of the posts other than
+	// owner, which took it over.
+	ReleaseSlug(ctx context.Context, slug string, owner primitive.ObjectID) error
+	// SamplePosts returns up to n published posts picked at random.
+	SamplePosts(ctx context.Context, n int) ([]models.Post, error)
+	// InsertPost stores a new post. It returns ErrDuplicate when another
+	// post has the same slug.
+	InsertPost(ctx context.Context, post models.Post) error
+	DeletePost(ctx context.Context, id primitive.ObjectID) error
+	// IncrementPostReactions adds deltas to the reaction counters of a post
+	// and their sum to its total.
+	IncrementPostReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error
+	// UpdatePost overwrites the title, tags, content, status, publish time,
+	// slugs and last updated time of an existing post. It returns
+	// ErrDuplicate when another post has the same slug.
+	UpdatePost(ctx context.Context, post models.Post) error
+	// TrashPost moves a post to the trash, which hides it from listings.
+	TrashPost(ctx context.Context, id primitive.ObjectID, trash models.Trash) error
+	// RestorePost takes a post out of the trash.
+	RestorePost(ctx context.Context, id primitive.ObjectID) error
+	// ListTrashedPosts returns one page of the posts in the trash. It
+	// returns ErrInvalidCursor for a cursor it did not produce.
+	ListTrashedPosts(ctx context.Context, q TrashQuery) (PostPage, error)
+	// PublishDuePosts publishes the scheduled posts whose publish time is
+	// not after now and returns their IDs.
+	PublishDuePosts(ctx context.Context, now time.Time) ([]primitive.ObjectID, error)
+	// TagCounts counts the published posts of every tag in use.
+	TagCounts(ctx context.Context) ([]TagCount, error)
+	// RelatedTags counts, for every other tag, the published posts tagged
+	// with both it and slug. Posts in the trash count for neither.
+	RelatedTags(ctx context.Context, slug string) ([]TagCount, error)
+	// ReplaceTags replaces the tags from with the tag to in every post,
+	// marking the posts as updated at now, and returns their IDs.
+	ReplaceTags(ctx context.Context, from []string, to string, now time.Time) ([]primitive.ObjectID, error)
+}
+
+// TagCount is the number of posts carrying a tag.
+type TagCount struct {
+	Slug  string `json:"slug" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+// TagStore persists the tag taxonomy.
+type TagStore interface {
+	ListTags(ctx context.Context) ([]models.Tag, error)
+	GetTag(ctx context.Context, slug string) (models.Tag, error)
+	// EnsureTag inserts tag unless a tag with the same slug exists.
+	EnsureTag(ctx context.Context, tag models.Tag) error
+	// SaveTag inserts or replaces tag.
+	SaveTag(ctx context.Context, tag models.Tag) error
+	DeleteTag(ctx context.Context, slug string) error
+	// ResolveTag returns the slug of the tag that slug is an alias of, or
+	// slug itself when it is no alias.
+	ResolveTag(ctx context.Context, slug string) (string, error)
+}
+
+// RevisionStore persists the edit history of posts.
+type RevisionStore interface {
+	InsertRevision(ctx context.Context, revision models.Revision) error
+	// ListRevisions returns the revisions of a post, newest first.
+	ListRevisions(ctx context.Context, postID primitive.ObjectID) ([]models.Revision, error)
+	GetRevision(ctx context.Context, id primitive.ObjectID) (models.Revision, error)
+	// DeleteRevisions removes all revisions of a post.
+	DeleteRevisions(ctx context.Context, postID primitive.ObjectID) error
+}
+
+// CommentStore persists comments made to posts.
+type CommentStore interface {
+	ListCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]models.Comment, error)
+	GetComment(ctx context.Context, id primitive.ObjectID) (models.Comment, error)
+	InsertComment(ctx context.Context, comment models.Comment) error
+	IncrementCommentReactions(ctx context.Context, id primitive.ObjectID, deltas map[models.ReactionType]int64) error
+	UpdateCommentContent(ctx context.Context, id primitive.ObjectID, content string) error
+	DeleteComment(ctx context.Context, id primitive.ObjectID) error
+	// ListCommentThreads returns one page of the top level comments of a
+	// post, oldest first, together with all replies to them. Top level
+	// comments in the trash or tombstoned are left out unless a reply in
+	// their thread is not in the trash.
+	ListCommentThreads(ctx context.Context, postID primitive.ObjectID, limit, offset int) (CommentThreads, error)
+	CountReplies(ctx context.Context, id primitive.ObjectID) (int64, error)
+	// TombstoneComment marks a comment deleted and clears its author,
+	// content and reaction counts, keeping it in place for its replies. It
+	// also takes the comment out of the trash.
+	TombstoneComment(ctx context.Context, id primitive.ObjectID) error
+	// TrashComment moves a comment to the trash.
+	TrashComment(ctx context.Context, id primitive.ObjectID, trash models.Trash) error
+	// RestoreComment takes a comment out of the trash.
+	RestoreComment(ctx context.Context, id primitive.ObjectID) error
+	// ListTrashedComments is ListTrashedPosts for comments.
+	ListTrashedComments(ctx context.Context, q TrashQuery) (CommentPage, error)
+	// DeleteCommentsToPost removes all comments to a post and returns their
+	// IDs.
+	DeleteCommentsToPost(ctx context.Context, postID primitive.ObjectID) ([]primitive.ObjectID, error)
+}
+
+// CommentThreads is one page of comment threads of a post.
+type CommentThreads struct {
+	Roots   []models.Comment
+	Replies []models.Comment
+	// Total counts the top level comments on all pages.
+	Total int64
+}
+
+// UserStore persists user accounts.
+type UserStore interface {
+	FindUserByUsername(ctx context.Context, username string) (models.User, error)
+	InsertUser(ctx context.Context, user models.User) error
+	ListUsers(ctx context.Context) ([]models.User, error)
+	UpdateUserRole(ctx context.Context, username string, role models.Role) error
+	SetUserDisabled(ctx context.Context, username string, disabled bool) error
+	// UpdateUserPassword replaces the stored password hash of a user.
+	UpdateUserPassword(ctx context.Context, username, hash string) error
+}
+
+// ReactionStore persists the reactions of users to posts and comments, at
+// most one per user and target.
+type ReactionStore interface {
+	// SetReaction records r, replacing an earlier reaction of the same user
+	// to the same target, and returns the type it replaced or "" if none.
+	SetReaction(ctx context.Context, r models.Reaction) (models.ReactionType, error)
+	// RemoveReaction deletes the reaction of username to a target and
+	// returns its type.
+	RemoveReaction(ctx context.Context, targetID primitive.ObjectID, username string) (models.ReactionType, error)
+	ListReactions(ctx context.Context, targetID primitive.ObjectID) ([]models.Reaction, error)
+	// UserReactions returns the reactions of username to any of targetIDs
+	// keyed by target.
+	UserReactions(ctx context.Context, username string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]models.ReactionType, error)
+	// DeleteReactions removes all reactions to any of targetIDs.
+	DeleteReactions(ctx context.Context, targetIDs []primitive.ObjectID) error
+}
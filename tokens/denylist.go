@@ -0,0 +1,78 @@
+package tokens
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Denylist remembers revoked token IDs until the tokens expire. Revoke
+// reports false when id was revoked already, so that of two concurrent
+// revocations only one succeeds.
+type Denylist interface {
+	Revoke(ctx context.Context, id string, until time.Time) (bool, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+const denylistPrefix = "token_denylist:"
+
+// RedisDenylist keeps revoked token IDs in Redis keys that expire together
+// with the tokens.
+type RedisDenylist struct {
+	redisClient *redis.Client
+}
+
+func NewRedisDenylist(redisClient *redis.Client) *RedisDenylist {
+	return &RedisDenylist{redisClient: redisClient}
+}
+
+func (d *RedisDenylist) Revoke(ctx context.Context, id string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return true, nil
+	}
+	return d.redisClient.WithContext(ctx).SetNX(denylistPrefix+id, 1, ttl).Result()
+}
+
+func (d *RedisDenylist) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := d.redisClient.WithContext(ctx).Exists(denylistPrefix + id).Result()
+	return n > 0, err
+}
+
+// MemoryDenylist keeps revoked token IDs in process memory. It is meant for
+// tests and single instance deployments.
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{revoked: make(map[string]time.Time)}
+}
+
+func (d *MemoryDenylist) Revoke(ctx context.Context, id string, until time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for other, expiry := range d.revoked {
+		if now.After(expiry) {
+			delete(d.revoked, other)
+		}
+	}
+	if _, ok := d.revoked[id]; ok {
+		return false, nil
+	}
+	d.revoked[id] = until
+	return true, nil
+}
+
+func (d *MemoryDenylist) IsRevoked(ctx context.Context, id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.revoked[id]
+	return ok, nil
+}
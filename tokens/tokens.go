@@ -0,0 +1,148 @@
+// Package tokens issues and verifies the JSON Web Tokens used by clients
+// that cannot carry the session cookie.
+//
+// A sign in yields a short lived access token, sent as a Bearer token on
+// every request, and a long lived refresh token that can be traded once for
+// a new pair. Revoked tokens are remembered in a Denylist until they expire.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rs/xid"
+)
+
+// Kind tells access and refresh tokens apart so that one cannot be used in
+// place of the other.
+type Kind string
+
+const (
+	Access  Kind = "access"
+	Refresh Kind = "refresh"
+)
+
+const issuer = "blogo"
+
+var (
+	// ErrInvalid is returned for malformed, expired or wrongly signed
+	// tokens and for tokens of the wrong kind.
+	ErrInvalid = errors.New("invalid token")
+	// ErrRevoked is returned for tokens found in the denylist.
+	ErrRevoked = errors.New("token revoked")
+)
+
+// Claims are the claims carried by every token. The subject is the
+// username and the ID is unique per token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Kind Kind `json:"kind"`
+}
+
+// Pair is what a client receives after signing in or refreshing.
+type Pair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+type Manager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	denylist   Denylist
+}
+
+// NewManager creates a Manager signing tokens with HMAC-SHA256 and secret.
+func NewManager(secret []byte, accessTTL, refreshTTL time.Duration, denylist Denylist) *Manager {
+	return &Manager{
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		denylist:   denylist,
+	}
+}
+
+// Issue creates a new token pair for username.
+func (m *Manager) Issue(username string) (Pair, error) {
+	access, err := m.sign(username, Access, m.accessTTL)
+	if err != nil {
+		return Pair{}, err
+	}
+	refresh, err := m.sign(username, Refresh, m.refreshTTL)
+	if err != nil {
+		return Pair{}, err
+	}
+	return Pair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.accessTTL / time.Second),
+	}, nil
+}
+
+// Parse verifies a token of the given kind and returns its claims.
+func (m *Manager) Parse(ctx context.Context, raw string, kind Kind) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalid
+		}
+		return m.secret, nil
+	})
+	if err != nil || claims.Kind != kind || claims.Issuer != issuer || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalid
+	}
+
+	revoked, err := m.denylist.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevoked
+	}
+	return claims, nil
+}
+
+// Refresh trades a refresh token for a new pair. The refresh token is
+// revoked so that it cannot be used again. Of concurrent refreshes with the
+// same token, all but the first fail with ErrRevoked.
+func (m *Manager) Refresh(ctx context.Context, raw string) (Pair, *Claims, error) {
+	claims, err := m.Parse(ctx, raw, Refresh)
+	if err != nil {
+		return Pair{}, nil, err
+	}
+	revoked, err := m.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return Pair{}, nil, err
+	}
+	if !revoked {
+		return Pair{}, nil, ErrRevoked
+	}
+	pair, err := m.Issue(claims.Subject)
+	return pair, claims, err
+}
+
+// Revoke adds the token to the denylist until it would have expired anyway.
+func (m *Manager) Revoke(ctx context.Context, claims *Claims) error {
+	_, err := m.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	return err
+}
+
+func (m *Manager) sign(username string, kind Kind, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   username,
+			ID:        xid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Kind: kind,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
@@ -0,0 +1,116 @@
+package tokens
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager([]byte("secret"), time.Minute, time.Hour, NewMemoryDenylist())
+	pair, err := m.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := m.Parse(ctx, pair.AccessToken, Access)
+	if err != nil || claims.Subject != "alice" {
+		t.Fatalf("Parse(access) = %+v, %v, want alice", claims, err)
+	}
+	if _, err := m.Parse(ctx, pair.AccessToken, Refresh); err != ErrInvalid {
+		t.Errorf("Parse(access as refresh) = %v, want ErrInvalid", err)
+	}
+	if _, err := m.Parse(ctx, pair.AccessToken+"x", Access); err != ErrInvalid {
+		t.Errorf("Parse(tampered) = %v, want ErrInvalid", err)
+	}
+
+	other := NewManager([]byte("other"), time.Minute, time.Hour, NewMemoryDenylist())
+	if _, err := other.Parse(ctx, pair.AccessToken, Access); err != ErrInvalid {
+		t.Errorf("Parse with another secret = %v, want ErrInvalid", err)
+	}
+
+	expired := NewManager([]byte("secret"), -time.Minute, time.Hour, NewMemoryDenylist())
+	pair, err = expired.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expired.Parse(ctx, pair.AccessToken, Access); err != ErrInvalid {
+		t.Errorf("Parse(expired) = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager([]byte("secret"), time.Minute, time.Hour, NewMemoryDenylist())
+	pair, err := m.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, claims, err := m.Refresh(ctx, pair.RefreshToken)
+	if err != nil || claims.Subject != "alice" {
+		t.Fatalf("Refresh = %+v, %v, want alice", claims, err)
+	}
+	if _, _, err := m.Refresh(ctx, pair.RefreshToken); err != ErrRevoked {
+		t.Errorf("second Refresh with the same token = %v, want ErrRevoked", err)
+	}
+	if _, err := m.Parse(ctx, next.RefreshToken, Refresh); err != nil {
+		t.Errorf("Parse(new refresh token) = %v", err)
+	}
+
+	access, err := m.Parse(ctx, next.AccessToken, Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Revoke(ctx, access); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(ctx, next.AccessToken, Access); err != ErrRevoked {
+		t.Errorf("Parse(revoked) = %v, want ErrRevoked", err)
+	}
+}
+
+func TestRefreshConcurrently(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	for name, denylist := range map[string]Denylist{
+		"memory": NewMemoryDenylist(),
+		"redis":  NewRedisDenylist(client),
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager([]byte("secret"), time.Minute, time.Hour, denylist)
+			pair, err := m.Issue("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const n = 10
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				go func() {
+					_, _, err := m.Refresh(context.Background(), pair.RefreshToken)
+					errs <- err
+				}()
+			}
+			succeeded := 0
+			for i := 0; i < n; i++ {
+				switch err := <-errs; err {
+				case nil:
+					succeeded++
+				case ErrRevoked:
+				default:
+					t.Errorf("Refresh = %v", err)
+				}
+			}
+			if succeeded != 1 {
+				t.Errorf("%d of %d concurrent refreshes succeeded, want 1", succeeded, n)
+			}
+		})
+	}
+}
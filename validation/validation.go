@@ -0,0 +1,124 @@
+// Package validation checks request bodies against the rules declared in
+// the binding struct tags of the models, such as
+//
+//	Title string `json:"postTitle" binding:"required,max=200"`
+//
+// gin applies the rules when it binds a body. Besides the rules of
+// go-playground/validator, username and password may be used for user names
+// and passwords.
+package validation
+
+import (
+	"blogo/apierror"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Setup registers the rules of this package with the validator of gin and
+// makes it report fields by their JSON names. It must be called before
+// bodies are bound.
+func Setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unsupported validator %T", binding.Validator.Engine())
+	}
+	v.RegisterTagNameFunc(jsonName)
+	if err := v.RegisterValidation("username", isUsername); err != nil {
+		return err
+	}
+	return v.RegisterValidation("password", isPassword)
+}
+
+// Struct checks the fields of the struct s.
+func Struct(s interface{}) error {
+	return binding.Validator.ValidateStruct(s)
+}
+
+// Fields returns the field errors err consists of. ok is false when err is
+// not a validation error.
+func Fields(err error) (fields []apierror.FieldError, ok bool) {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil, false
+	}
+	for _, fe := range errs {
+		fields = append(fields, apierror.FieldError{Field: fieldName(fe), Message: message(fe)})
+	}
+	return fields, true
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldName is the path to the field below the validated struct, such as
+// postTags[2].
+func fieldName(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func message(fe validator.FieldError) string {
+	counted := "characters"
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		counted = "items"
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must have at least %s %s", fe.Param(), counted)
+	case "max":
+		return fmt.Sprintf("must have at most %s %s", fe.Param(), counted)
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "username":
+		return "may only contain letters, digits, '.', '_' and '-'"
+	case "password":
+		return "must contain a letter and a digit"
+	}
+	return fmt.Sprintf("fails the %s rule", fe.Tag())
+}
+
+// isUsername accepts ASCII letters, digits, '.', '_' and '-', so that user
+// names are safe in paths.
+func isUsername(fl validator.FieldLevel) bool {
+	for _, r := range fl.Field().String() {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isPassword requires at least one letter and one digit. The length is left
+// to min and max.
+func isPassword(fl validator.FieldLevel) bool {
+	var letter, digit bool
+	for _, r := range fl.Field().String() {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}